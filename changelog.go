@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/util"
+)
+
+var changelogCommand = cli.Command{
+	Name:      "changelog",
+	Usage:     "Print the upstream commits between two locks as Markdown",
+	ArgsUsage: "[old-lock] [new-lock]",
+	Description: `The locks are files or git objects like 'master:trash.lock'. The old lock defaults
+   to 'HEAD:trash.lock' and the new one to './trash.lock', so running it after 'trash -u'
+   describes the uncommitted vendor bump. The commits come from the cached repos: run
+   trash first if they are missing.`,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "max-commits",
+			Value: 50,
+			Usage: "Maximum number of commits listed for each dependency (0 for all)",
+		},
+	},
+	Action: changelog,
+}
+
+type lockChange struct {
+	Package  string
+	Old, New conf.Import
+	Commits  []string
+	// Note flags downgrades, force pushes and revisions that are missing from the cache
+	Note string
+}
+
+func changelog(c *cli.Context) error {
+	if c.NArg() > 2 {
+		cli.ShowCommandHelp(c, "changelog")
+		return fmt.Errorf("expected at most 2 arguments, got %d", c.NArg())
+	}
+	dir, trashDir, err := prepareDir(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	defer os.Chdir(dir)
+
+	oldLock, newLock := "HEAD:trash.lock", "trash.lock"
+	if c.NArg() > 0 {
+		oldLock = c.Args()[0]
+	}
+	if c.NArg() > 1 {
+		newLock = c.Args()[1]
+	}
+	oldConf, err := readLock(oldLock)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	newConf, err := readLock(newLock)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+
+	changes, added, removed := diffLocks(oldConf, newConf)
+	for k := range changes {
+		changes[k].collectCommits(trashDir, c.Int("max-commits"))
+	}
+	printChangelog(oldLock, newLock, changes, added, removed)
+	return nil
+}
+
+// readLock parses a lock file or, if there's no such file, a git object like 'HEAD:trash.lock'
+func readLock(lock string) (*conf.Conf, error) {
+	if _, err := os.Stat(lock); err == nil {
+		return conf.Parse(lock)
+	}
+	bytes, err := exec.Command("git", "show", lock).Output()
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is neither a file nor a git object: %s", lock, err)
+	}
+	file, err := ioutil.TempFile("", "trash.lock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err := file.Write(bytes); err != nil {
+		return nil, err
+	}
+	return conf.Parse(file.Name())
+}
+
+// diffLocks pairs the imports of the two locks by package
+func diffLocks(oldConf, newConf *conf.Conf) (changes []lockChange, added, removed []conf.Import) {
+	changes = []lockChange{}
+	for _, n := range newConf.Imports {
+		o, ok := oldConf.Get(n.Package)
+		switch {
+		case !ok:
+			added = append(added, n)
+		case o.Version != n.Version || o.Repo != n.Repo || o.Commit != n.Commit:
+			changes = append(changes, lockChange{Package: n.Package, Old: o, New: n})
+		}
+	}
+	for _, o := range oldConf.Imports {
+		if _, ok := newConf.Get(o.Package); !ok {
+			removed = append(removed, o)
+		}
+	}
+	sort.Sort(byChangedPackage(changes))
+	return changes, added, removed
+}
+
+// collectCommits lists the commits from the old revision to the new one, using the cached repo: each revision's branch
+// is looked up in the remote of its own repo, the old and new ones differing when the import moved to a fork
+func (l *lockChange) collectCommits(trashDir string, max int) {
+	if err := os.Chdir(path.Join(trashDir, "src", l.Package)); err != nil || !isCurrentDirARepo(trashDir) {
+		l.Note = "not in the cache: run trash first"
+		return
+	}
+	oldRev, err := revision(remoteName(l.Old.Repo), l.Old)
+	if err != nil {
+		l.Note = err.Error()
+		return
+	}
+	newRev, err := revision(remoteName(l.New.Repo), l.New)
+	if err != nil {
+		l.Note = err.Error()
+		return
+	}
+
+	from, to := oldRev, newRev
+	switch {
+	case isAncestor(oldRev, newRev):
+	case isAncestor(newRev, oldRev):
+		from, to = newRev, oldRev
+		l.Note = "downgrade: these commits are removed"
+	default:
+		bytes, err := exec.Command("git", "merge-base", oldRev, newRev).Output()
+		if err != nil {
+			l.Note = "**not an ancestor**: the old revision is not in the history of the new one, and they have nothing in common"
+			return
+		}
+		from = strings.TrimSpace(string(bytes))
+		l.Note = "**not an ancestor**: the old revision is not in the history of the new one (force push, or moved to another branch); commits since the fork point"
+	}
+
+	args := []string{"log", "--date=short", "--format=%h %cd %s", from + ".." + to}
+	if max > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", max+1))
+	}
+	for line := range util.CmdOutLines(exec.Command("git", args...)) {
+		l.Commits = append(l.Commits, strings.TrimSpace(line))
+	}
+	if max > 0 && len(l.Commits) > max {
+		l.Commits = append(l.Commits[:max], "...")
+	}
+}
+
+// revision resolves the version of an import to a commit in the current dir's repo: branches are taken from the remote
+func revision(remote string, i conf.Import) (string, error) {
+	version := i.Version
+	if i.Commit != "" {
+		version = i.Commit
+	} else if isBranch(remote, version) {
+		version = remote + "/" + version
+	}
+	bytes, err := exec.Command("git", "rev-parse", "--verify", "-q", version+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s is not in the cached repo: run trash first", i.Version)
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+func isAncestor(ancestor, rev string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", ancestor, rev).Run() == nil
+}
+
+func printChangelog(oldLock, newLock string, changes []lockChange, added, removed []conf.Import) {
+	fmt.Printf("## Vendor changes (%s → %s)\n", oldLock, newLock)
+	if len(changes)+len(added)+len(removed) == 0 {
+		fmt.Println("\nNo changes.")
+		return
+	}
+	for _, l := range changes {
+		fmt.Printf("\n### %s: %s → %s\n\n", l.Package, displayVersion(l.Old), displayVersion(l.New))
+		if url := compareURL(l); url != "" {
+			fmt.Printf("[Compare on GitHub](%s)\n\n", url)
+		}
+		if l.Note != "" {
+			fmt.Printf("> %s\n\n", l.Note)
+		}
+		for _, commit := range l.Commits {
+			fmt.Printf("- %s\n", commit)
+		}
+	}
+	if len(added) > 0 {
+		fmt.Printf("\n### Added\n\n")
+		for _, i := range added {
+			fmt.Printf("- %s %s\n", i.Package, displayVersion(i))
+		}
+	}
+	if len(removed) > 0 {
+		fmt.Printf("\n### Removed\n\n")
+		for _, i := range removed {
+			fmt.Printf("- %s %s\n", i.Package, displayVersion(i))
+		}
+	}
+}
+
+// compareURL links to the GitHub compare view for the github.com dependencies that don't use another repo
+func compareURL(l lockChange) string {
+	if l.New.Repo != "" || !strings.HasPrefix(l.Package, "github.com/") {
+		return ""
+	}
+	parts := strings.Split(l.Package, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return fmt.Sprintf("https://%s/compare/%s...%s", strings.Join(parts[:3], "/"), compareRev(l.Old), compareRev(l.New))
+}
+
+// compareRev is what GitHub compares: the tag or commit the version resolved to, or the version
+func compareRev(i conf.Import) string {
+	switch {
+	case i.Ref != "":
+		return i.Ref
+	case i.Commit != "":
+		return i.Commit
+	}
+	return i.Version
+}
+
+type byChangedPackage []lockChange
+
+func (l byChangedPackage) Len() int           { return len(l) }
+func (l byChangedPackage) Less(i, j int) bool { return l[i].Package < l[j].Package }
+func (l byChangedPackage) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestDiffLocks(t *testing.T) {
+	assert := require.New(t)
+
+	oldConf := &conf.Conf{Imports: []conf.Import{
+		{Package: "github.com/a/a", Version: "v1.0.0"},
+		{Package: "example.com/b", Version: "v1"},
+		{Package: "example.com/gone", Version: "v1"},
+		{Package: "example.com/moved", Version: "v1"},
+	}}
+	oldConf.Dedupe()
+	newConf := &conf.Conf{Imports: []conf.Import{
+		{Package: "github.com/a/a", Version: "v1.1.0"},
+		{Package: "example.com/b", Version: "v1"},
+		{Package: "example.com/c", Version: "v2"},
+		{Package: "example.com/moved", Version: "v1", Repo: "https://example.com/fork"},
+	}}
+	newConf.Dedupe()
+
+	changes, added, removed := diffLocks(oldConf, newConf)
+	assert.Len(changes, 2)
+	assert.Equal("example.com/moved", changes[0].Package)
+	assert.Equal("github.com/a/a", changes[1].Package)
+	assert.Equal("v1.0.0", changes[1].Old.Version)
+	assert.Equal("v1.1.0", changes[1].New.Version)
+	assert.Equal([]conf.Import{{Package: "example.com/c", Version: "v2"}}, added)
+	assert.Equal([]conf.Import{{Package: "example.com/gone", Version: "v1"}}, removed)
+
+	assert.Equal("https://github.com/a/a/compare/v1.0.0...v1.1.0", compareURL(changes[1]))
+	assert.Equal("", compareURL(changes[0]))
+}
+
+func TestCollectCommitsMovedToFork(t *testing.T) {
+	assert := require.New(t)
+
+	trashDir, err := ioutil.TempDir("", "trash-changelog")
+	assert.Nil(err)
+	defer os.RemoveAll(trashDir)
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	repoDir := filepath.Join(trashDir, "src", "example.com", "moved")
+	assert.Nil(os.MkdirAll(repoDir, 0755))
+	assert.Nil(os.Chdir(repoDir))
+	git := func(args ...string) string {
+		bytes, err := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...).CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+		return strings.TrimSpace(string(bytes))
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "upstream")
+	git("update-ref", "refs/remotes/origin/develop", git("rev-parse", "HEAD"))
+	git("commit", "-q", "--allow-empty", "-m", "fork")
+	git("update-ref", "refs/remotes/"+remoteName("https://example.com/fork")+"/develop", git("rev-parse", "HEAD"))
+
+	l := lockChange{
+		Package: "example.com/moved",
+		Old:     conf.Import{Package: "example.com/moved", Version: "develop"},
+		New:     conf.Import{Package: "example.com/moved", Version: "develop", Repo: "https://example.com/fork"},
+	}
+	l.collectCommits(trashDir, 0)
+	assert.Equal("", l.Note)
+	assert.Len(l.Commits, 1, "the old develop is origin's, the new one the fork's")
+	assert.Contains(l.Commits[0], "fork")
+}
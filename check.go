@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+)
+
+var checkCommand = cli.Command{
+	Name:  "check",
+	Usage: "Report missing, unused and undeclared dependencies without fetching anything",
+	Description: `Exits with 1 if there are problems of the kinds listed in --fail-on,
+   with 2 if the check itself failed.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the report as JSON",
+		},
+		cli.StringFlag{
+			Name:  "fail-on",
+			Value: "missing,unused",
+			Usage: "Comma separated kinds of problems to exit non-zero on: missing, unused, undeclared",
+		},
+	},
+	Action: check,
+}
+
+type missingPackage struct {
+	Package string `json:"package"`
+	Reason  string `json:"reason"`
+}
+
+type checkReport struct {
+	Missing    []missingPackage `json:"missing"`
+	Unused     []conf.Import    `json:"unused"`
+	Undeclared []string         `json:"undeclared"`
+}
+
+func check(c *cli.Context) error {
+	dir, _, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return cli.NewExitError(err.Error(), 2)
+	}
+	failOn := map[string]bool{}
+	for _, kind := range strings.Split(c.String("fail-on"), ",") {
+		switch kind = strings.TrimSpace(kind); kind {
+		case "missing", "unused", "undeclared":
+			failOn[kind] = true
+		case "":
+		default:
+			return cli.NewExitError(fmt.Sprintf("unknown kind of problem in --fail-on: '%s'", kind), 2)
+		}
+	}
+
+	report, err := checkDeps(dir, c.GlobalString("target"), trashConf)
+	if err != nil {
+		logrus.Error(err)
+		return cli.NewExitError(err.Error(), 2)
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		fmt.Println(string(data))
+	} else {
+		report.print()
+	}
+
+	if failOn["missing"] && len(report.Missing) > 0 ||
+		failOn["unused"] && len(report.Unused) > 0 ||
+		failOn["undeclared"] && len(report.Undeclared) > 0 {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+// checkDeps compares the project's imports with what's in the vendor dir and in the conf
+func checkDeps(dir, targetDir string, trashConf *conf.Conf) (*checkReport, error) {
+	rootPackage := trashConf.Package
+	if rootPackage == "" {
+		rootPackage = guessRootPackage(dir)
+	}
+	os.Chdir(dir)
+
+	report := &checkReport{Missing: []missingPackage{}, Unused: []conf.Import{}, Undeclared: []string{}}
+	used := map[string]bool{}
+	imports := collectImports(rootPackage, targetDir, targetDir)
+	for _, pkg := range trashConf.Packages {
+		imports[pkg] = true
+	}
+	for pkg := range imports {
+		if pkg == rootPackage || strings.HasPrefix(pkg, rootPackage+"/") {
+			continue
+		}
+		i, declared := coveringImport(trashConf, pkg)
+		if declared {
+			used[i.Package] = true
+		}
+		if hasSources(filepath.Join(targetDir, pkg)) {
+			continue
+		}
+		if declared {
+			report.Missing = append(report.Missing, missingPackage{pkg, fmt.Sprintf("in %s but not vendored: run trash", trashConf.ConfFile())})
+		} else {
+			report.Missing = append(report.Missing, missingPackage{pkg, fmt.Sprintf("neither vendored nor in %s", trashConf.ConfFile())})
+		}
+	}
+	sort.Sort(byPackage(report.Missing))
+
+	for _, i := range trashConf.Imports {
+		if !used[i.Package] {
+			report.Unused = append(report.Unused, i)
+		}
+	}
+
+	undeclared := map[string]bool{}
+	err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		pkg := filepath.Dir(path)[len(targetDir+"/"):]
+		if _, ok := coveringImport(trashConf, pkg); !ok {
+			undeclared[pkg] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for pkg := range undeclared {
+		if parent := topmostPackage(undeclared, pkg); parent == pkg {
+			report.Undeclared = append(report.Undeclared, pkg)
+		}
+	}
+	sort.Strings(report.Undeclared)
+	return report, nil
+}
+
+// hasSources tells if dir has non-test Go files (or C files for cgo includes):
+// after pruning, dirs of unused packages are left if they are parents of used packages
+func hasSources(dir string) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		switch ext := filepath.Ext(f.Name()); {
+		case f.IsDir():
+		case ext == ".go" && !strings.HasSuffix(f.Name(), "_test.go"), ext == ".c", ext == ".h":
+			return true
+		}
+	}
+	return false
+}
+
+// topmostPackage finds the top parent of pkg in packages
+func topmostPackage(packages map[string]bool, pkg string) string {
+	top := pkg
+	for p := filepath.Dir(pkg); p != "."; p = filepath.Dir(p) {
+		if packages[p] {
+			top = p
+		}
+	}
+	return top
+}
+
+func (r *checkReport) print() {
+	if len(r.Missing)+len(r.Unused)+len(r.Undeclared) == 0 {
+		fmt.Println("All good")
+		return
+	}
+	if len(r.Missing) > 0 {
+		fmt.Println("Missing (imported, but not vendored):")
+		for _, m := range r.Missing {
+			fmt.Printf("  %s: %s\n", m.Package, m.Reason)
+		}
+	}
+	if len(r.Unused) > 0 {
+		fmt.Println("Unused (declared, but not imported):")
+		for _, i := range r.Unused {
+			fmt.Printf("  %s %s\n", i.Package, i.Version)
+		}
+	}
+	if len(r.Undeclared) > 0 {
+		fmt.Println("Undeclared (vendored, but not declared):")
+		for _, pkg := range r.Undeclared {
+			fmt.Printf("  %s\n", pkg)
+		}
+	}
+}
+
+type byPackage []missingPackage
+
+func (m byPackage) Len() int           { return len(m) }
+func (m byPackage) Less(i, j int) bool { return m[i].Package < m[j].Package }
+func (m byPackage) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
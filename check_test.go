@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+// writeTree creates the files (path -> content) under a new temp dir and returns it
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for f, content := range files {
+		f = filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCheckDeps(t *testing.T) {
+	assert := require.New(t)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	dir := writeTree(t, map[string]string{
+		"vendor.conf":                     "example.com/proj\nexample.com/a v1\nexample.com/d v1\n",
+		"main.go":                         "package main\nimport (\n_ \"example.com/a/lib\"\n_ \"example.com/b\"\n)\n",
+		"vendor/example.com/a/lib/lib.go": "package lib\nimport _ \"example.com/c\"\n",
+		"vendor/example.com/c/c.go":       "package c\n",
+		"vendor/example.com/c/sub/sub.go": "package sub\n",
+	})
+	defer os.RemoveAll(dir)
+	os.Chdir(dir)
+
+	trashConf, err := conf.Parse("vendor.conf")
+	assert.Nil(err)
+	report, err := checkDeps(dir, "vendor", trashConf)
+	assert.Nil(err)
+
+	assert.Len(report.Missing, 1)
+	assert.Equal("example.com/b", report.Missing[0].Package)
+	assert.Len(report.Unused, 1)
+	assert.Equal("example.com/d", report.Unused[0].Package)
+	assert.Equal([]string{"example.com/c"}, report.Undeclared)
+}
@@ -2,8 +2,12 @@ package conf
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -12,21 +16,45 @@ import (
 )
 
 type Conf struct {
-	Package   string            `yaml:"package,omitempty"`
-	Imports   []Import          `yaml:"import,omitempty"`
-	Excludes  []string          `yaml:"exclude,omitempty"`
-	Packages  []string          `yaml:"packages,omitempty"`
-	ImportMap map[string]Import `yaml:"-"`
-	confFile  string            `yaml:"-"`
-	yamlType  bool              `yaml:"-"`
+	Package  string   `yaml:"package,omitempty"`
+	Imports  []Import `yaml:"import,omitempty"`
+	Excludes []string `yaml:"exclude,omitempty"`
+	Packages []string `yaml:"packages,omitempty"`
+	// Overrides force the version or repo of packages anywhere in the dependency graph,
+	// and IgnoreTransitive patterns drop the packages transitive imports pull in
+	Overrides        []Import `yaml:"override,omitempty"`
+	IgnoreTransitive []string `yaml:"ignore-transitive,omitempty"`
+	// Flattened records in trash.lock the packages of the dependencies' vendor dirs, flattened into the top-level vendor dir
+	Flattened    []Flattened       `yaml:"flattened,omitempty"`
+	ImportMap    map[string]Import `yaml:"-"`
+	confFile     string            `yaml:"-"`
+	yamlType     bool              `yaml:"-"`
+	foreign      bool              `yaml:"-"` // read from another tool's file, which trash doesn't write
+	untranslated []string          `yaml:"-"`
 }
 
 type Import struct {
-	Package string `yaml:"package,omitempty"`
-	Version string `yaml:"version,omitempty"`
-	Repo    string `yaml:"repo,omitempty"`
-	Update  bool   `yaml:"-"`
+	Package string `yaml:"package,omitempty" json:"package"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	Repo    string `yaml:"repo,omitempty" json:"repo,omitempty"`
+	Update  bool   `yaml:"-" json:"-"`
 	Options `yaml:",inline"`
+	// From is the config file a transitive import comes from
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+	// Ref is the tag or ref a version constraint or ref version resolved to, and Commit the commit the import is checked out at:
+	// they are recorded in trash.lock
+	Ref    string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
+	// Reason is why this version won over the other versions transitive imports require, in trash.lock
+	Reason string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// Flattened is a package of a dependency's vendor dir, in trash.lock: From is the vendor dir the package was lifted from
+// ("" if a conf import has it already), and Conflicts the vendor dirs that have it with other content
+type Flattened struct {
+	Package   string   `yaml:"package"`
+	From      string   `yaml:"from,omitempty"`
+	Conflicts []string `yaml:"conflicts,omitempty"`
 }
 
 type Imports []Import
@@ -47,21 +75,76 @@ func (i Imports) Swap(k, j int) {
 }
 
 type Options struct {
-	Transitive bool `yaml:"transitive,omitempty"`
-	Staging    bool `yaml:"staging,omitempty"`
+	Transitive bool `yaml:"transitive,omitempty" json:"transitive,omitempty"`
+	// Staging is short for mapping staging/src/<parent of the package> to the parent of the package, as Kubernetes has it
+	Staging bool `yaml:"staging,omitempty" json:"staging,omitempty"`
+	// Mappings vendor subdirs of the repo as other packages
+	Mappings []Mapping `yaml:"map,omitempty" json:"map,omitempty"`
+	// IgnoreExcludes keeps a transitive import's conf file excludes and package= entries from applying to the vendor dir
+	IgnoreExcludes bool `yaml:"ignore-excludes,omitempty" json:"ignore-excludes,omitempty"`
+	// Test marks the imports only the project's tests need, like glide's testImport
+	Test bool `yaml:"test,omitempty" json:"test,omitempty"`
+}
+
+// Mapping says the package comes from the dir of the import's repo (relative to the repo root)
+type Mapping struct {
+	Package string `yaml:"package" json:"package"`
+	Dir     string `yaml:"dir" json:"dir"`
+}
+
+// ParseMapping reads the value of a map= option: <package>:<dir>
+func ParseMapping(value string) (Mapping, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Mapping{}, fmt.Errorf("malformed mapping '%s': expected <package>:<dir>", value)
+	}
+	m := Mapping{Package: strings.Trim(parts[0], "/"), Dir: path.Clean(parts[1])}
+	if path.IsAbs(m.Dir) || m.Dir == ".." || strings.HasPrefix(m.Dir, "../") {
+		return Mapping{}, fmt.Errorf("malformed mapping '%s': the dir must be inside the repo", value)
+	}
+	return m, nil
+}
+
+// PathMappings are the mappings of the import, with the one staging=true stands for
+func (i Import) PathMappings() []Mapping {
+	if !i.Staging {
+		return i.Mappings
+	}
+	parent := path.Dir(i.Package)
+	return append([]Mapping{{Package: parent, Dir: path.Join("staging/src", parent)}}, i.Mappings...)
 }
 
 type ExportMap struct {
 	Imports map[string]Import `yaml:"imports,omitempty"`
 }
 
+// foreignParsers read the files of other tools as confs, by file name
+var foreignParsers = map[string]func(path string, file io.Reader) (*Conf, error){
+	"glide.yaml":  parseGlide,
+	"glide.yml":   parseGlide,
+	"Gopkg.toml":  parseGopkgToml,
+	"Gopkg.lock":  parseGopkgLock,
+	"Godeps.json": parseGodeps,
+}
+
 func Parse(path string) (*Conf, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return parse(path, file)
+}
+
+// ParseBytes parses conf file contents read from somewhere else than path, e.g. a git object
+func ParseBytes(path string, data []byte) (*Conf, error) {
+	return parse(path, bytes.NewReader(data))
+}
 
+func parse(path string, file io.ReadSeeker) (*Conf, error) {
+	if parseForeign, ok := foreignParsers[filepath.Base(path)]; ok {
+		return parseForeign(path, file)
+	}
 	trashConf := &Conf{confFile: path}
 	if yaml.NewDecoder(file).Decode(trashConf) == nil {
 		trashConf.yamlType = true
@@ -70,8 +153,7 @@ func Parse(path string) (*Conf, error) {
 	}
 
 	trashConf = &Conf{confFile: path}
-	_, err = file.Seek(0, 0)
-	if err != nil {
+	if _, err := file.Seek(0, 0); err != nil {
 		return nil, err
 	}
 
@@ -86,7 +168,7 @@ func Parse(path string) (*Conf, error) {
 		}
 		fields := strings.Fields(line)
 
-		if len(fields) == 1 && trashConf.Package == "" {
+		if isRootPackageLine(fields, trashConf.Package) {
 			trashConf.Package = fields[0] // use the first 1-field line as the root package
 			logrus.Infof("Using '%s' as the project's root package (from %s)", trashConf.Package, trashConf.confFile)
 			continue
@@ -102,6 +184,24 @@ func Parse(path string) (*Conf, error) {
 			continue
 		}
 
+		if strings.HasPrefix(fields[0], "ignore-transitive=") {
+			trashConf.IgnoreTransitive = append(trashConf.IgnoreTransitive, strings.TrimPrefix(fields[0], "ignore-transitive="))
+			continue
+		}
+
+		// `override=package version [repo]` forces the version (and repo) wherever the package is imported
+		if strings.HasPrefix(fields[0], "override=") {
+			override := Import{Package: strings.TrimPrefix(fields[0], "override=")}
+			if len(fields) > 1 {
+				override.Version = fields[1]
+			}
+			if len(fields) > 2 {
+				override.Repo = fields[2]
+			}
+			trashConf.Overrides = append(trashConf.Overrides, override)
+			continue
+		}
+
 		// Otherwise it's an import pattern
 		packageImport := Import{}
 		packageImport.Package = fields[0] // at least 1 field at this point: trimmed the line and skipped empty
@@ -125,18 +225,47 @@ func Parse(path string) (*Conf, error) {
 	return trashConf, nil
 }
 
+// isRootPackageLine tells if the fields of a flat conf line are the root package, while there's none yet:
+// a single field, which is neither an exclude nor an entry like package=
+func isRootPackageLine(fields []string, rootPackage string) bool {
+	return len(fields) == 1 && rootPackage == "" && fields[0][0] != '-' && !strings.Contains(fields[0], "=")
+}
+
+// isImportLine tells if the fields of a flat conf line, which is not the root package, are an import:
+// excludes and package=, override= and ignore-transitive= entries are not
+func isImportLine(fields []string) bool {
+	for _, prefix := range []string{"-", "package=", "override=", "ignore-transitive="} {
+		if strings.HasPrefix(fields[0], prefix) {
+			return false
+		}
+	}
+	return true
+}
+
 // Other options besides include_transitive can be included in the future
 func parseOptions(options string) Options {
 	var importOptions Options
 	parts := strings.Split(options, ",")
 	for _, part := range parts {
-		kvParts := strings.Split(part, "=")
+		kvParts := strings.SplitN(part, "=", 2)
+		if len(kvParts) > 1 && kvParts[0] == "map" {
+			if m, err := ParseMapping(kvParts[1]); err == nil {
+				importOptions.Mappings = append(importOptions.Mappings, m)
+			} else {
+				logrus.Warn(err)
+			}
+			continue
+		}
 		if len(kvParts) > 1 && kvParts[1] == "true" {
 			switch kvParts[0] {
 			case "transitive":
 				importOptions.Transitive = true
 			case "staging":
 				importOptions.Staging = true
+			case "ignore-excludes":
+				importOptions.IgnoreExcludes = true
+			case "test":
+				importOptions.Test = true
 			}
 		}
 	}
@@ -171,6 +300,9 @@ func (t *Conf) Get(pkg string) (Import, bool) {
 }
 
 func (t *Conf) Dump(path string) error {
+	if _, ok := foreignParsers[filepath.Base(path)]; ok && t.foreign {
+		return fmt.Errorf("can't write %s: trash doesn't keep all of its fields, edit it by hand", path)
+	}
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -191,10 +323,28 @@ func (t *Conf) Dump(path string) error {
 	if len(t.Imports) > 0 {
 		fmt.Fprintln(w, "\n# import")
 		for _, i := range t.Imports {
-			s := fmt.Sprintf("%s\t%s\t%s", i.Package, i.Version, i.Repo)
+			s := fmt.Sprintf("%s\t%s\t%s\t%s", i.Package, i.Version, i.Repo, formatOptions(i.Options))
 			fmt.Fprintln(w, strings.TrimSpace(s))
 		}
 	}
+	if len(t.Overrides) > 0 {
+		fmt.Fprintln(w, "\n# override")
+		for _, i := range t.Overrides {
+			fmt.Fprintln(w, strings.TrimSpace(fmt.Sprintf("override=%s\t%s\t%s", i.Package, i.Version, i.Repo)))
+		}
+	}
+	if len(t.IgnoreTransitive) > 0 {
+		fmt.Fprintln(w, "\n# ignore-transitive")
+		for _, pkg := range t.IgnoreTransitive {
+			fmt.Fprintln(w, "ignore-transitive="+strings.TrimSpace(pkg))
+		}
+	}
+	if len(t.Packages) > 0 {
+		fmt.Fprintln(w, "\n# keep")
+		for _, pkg := range t.Packages {
+			fmt.Fprintln(w, "package="+strings.TrimSpace(pkg))
+		}
+	}
 	if len(t.Excludes) > 0 {
 		fmt.Fprintln(w, "\n# exclude")
 		for _, pkg := range t.Excludes {
@@ -207,3 +357,20 @@ func (t *Conf) Dump(path string) error {
 func (t *Conf) ConfFile() string {
 	return t.confFile
 }
+
+// SetYAML makes Dump write the conf in YAML, or in the flat format
+func (t *Conf) SetYAML(yamlType bool) {
+	t.yamlType = yamlType
+}
+
+// Untranslated lists what trash couldn't take from another tool's file
+func (t *Conf) Untranslated() []string {
+	return t.untranslated
+}
+
+// untranslatable warns about something in another tool's file trash can't do, and records it
+func (t *Conf) untranslatable(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Warn(msg)
+	t.untranslated = append(t.untranslated, msg)
+}
@@ -1,7 +1,13 @@
 package conf
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestDuplicates(t *testing.T) {
@@ -11,35 +17,35 @@ func TestDuplicates(t *testing.T) {
 		duplicates int
 	}{
 		{[]Import{
-			{"package1", "version1", ""},
+			{Package: "package1", Version: "version1", Repo: ""},
 		}, 0},
 		{[]Import{
-			{"package1", "version1", ""},
-			{"package2", "version1", "repoA"},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package2", Version: "version1", Repo: "repoA"},
 		}, 0},
 		{[]Import{
-			{"package1", "version1", ""},
-			{"package2", "version1", "repoA"},
-			{"package1", "version1", ""},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package2", Version: "version1", Repo: "repoA"},
+			{Package: "package1", Version: "version1", Repo: ""},
 		}, 1},
 		{[]Import{
-			{"package1", "version1", ""},
-			{"package2", "version1", "repoA"},
-			{"package1", "version1", ""},
-			{"package1", "version1", ""},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package2", Version: "version1", Repo: "repoA"},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package1", Version: "version1", Repo: ""},
 		}, 2},
 		{[]Import{
-			{"package1", "version1", ""},
-			{"package2", "version1", "repoA"},
-			{"package1", "version1", ""},
-			{"package1", "version1", ""},
-			{"package2", "version2", "repoB"},
-			{"package3", "version1", "repoA"},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package2", Version: "version1", Repo: "repoA"},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package1", Version: "version1", Repo: ""},
+			{Package: "package2", Version: "version2", Repo: "repoB"},
+			{Package: "package3", Version: "version1", Repo: "repoA"},
 		}, 3},
 	}
 
 	for i, d := range testData {
-		trash := Conf{"", d.imports, nil, nil, "", false}
+		trash := Conf{Imports: d.imports}
 		trash.Dedupe()
 
 		if d.duplicates != len(d.imports)-len(trash.Imports) {
@@ -49,3 +55,130 @@ func TestDuplicates(t *testing.T) {
 	}
 
 }
+
+func TestParseOverrides(t *testing.T) {
+	assert := require.New(t)
+
+	flat := writeTemp(t, "vendor.conf", `github.com/example/project
+github.com/example/a v1.0.0 transitive=true
+override=github.com/example/b v1.2.0
+override=github.com/example/c v0.3.0 https://github.com/fork/c.git
+ignore-transitive=github.com/example/d
+ignore-transitive=golang.org/x/*
+`)
+	yml := writeTemp(t, "trash.yml", `package: github.com/example/project
+import:
+- package: github.com/example/a
+  version: v1.0.0
+  transitive: true
+override:
+- package: github.com/example/b
+  version: v1.2.0
+- package: github.com/example/c
+  version: v0.3.0
+  repo: https://github.com/fork/c.git
+ignore-transitive:
+- github.com/example/d
+- golang.org/x/*
+`)
+	defer os.RemoveAll(filepath.Dir(flat))
+	defer os.RemoveAll(filepath.Dir(yml))
+
+	for _, f := range []string{flat, yml} {
+		c, err := Parse(f)
+		assert.Nil(err)
+		assert.Len(c.Imports, 1, f)
+		assert.Equal([]Import{
+			{Package: "github.com/example/b", Version: "v1.2.0"},
+			{Package: "github.com/example/c", Version: "v0.3.0", Repo: "https://github.com/fork/c.git"},
+		}, c.Overrides, f)
+		assert.Equal([]string{"github.com/example/d", "golang.org/x/*"}, c.IgnoreTransitive, f)
+	}
+}
+
+func TestParseMappings(t *testing.T) {
+	assert := require.New(t)
+
+	flat := writeTemp(t, "vendor.conf", `github.com/example/project
+k8s.io/kubernetes v1.10.0 map=k8s.io/api:staging/src/k8s.io/api,map=k8s.io/client-go:staging/src/k8s.io/client-go/
+k8s.io/apiserver v1.10.0 staging=true
+`)
+	yml := writeTemp(t, "trash.yml", `package: github.com/example/project
+import:
+- package: k8s.io/kubernetes
+  version: v1.10.0
+  map:
+  - package: k8s.io/api
+    dir: staging/src/k8s.io/api
+  - package: k8s.io/client-go
+    dir: staging/src/k8s.io/client-go
+- package: k8s.io/apiserver
+  version: v1.10.0
+  staging: true
+`)
+	defer os.RemoveAll(filepath.Dir(flat))
+	defer os.RemoveAll(filepath.Dir(yml))
+
+	for _, f := range []string{flat, yml} {
+		c, err := Parse(f)
+		assert.Nil(err)
+		kubernetes, _ := c.Get("k8s.io/kubernetes")
+		assert.Equal([]Mapping{
+			{Package: "k8s.io/api", Dir: "staging/src/k8s.io/api"},
+			{Package: "k8s.io/client-go", Dir: "staging/src/k8s.io/client-go"},
+		}, kubernetes.PathMappings(), f)
+		apiserver, _ := c.Get("k8s.io/apiserver")
+		assert.Equal([]Mapping{{Package: "k8s.io", Dir: "staging/src/k8s.io"}}, apiserver.PathMappings(), f)
+
+		assert.Nil(c.Dump(flat))
+		dumped, err := Parse(flat)
+		assert.Nil(err)
+		assert.Equal(c.Imports, dumped.Imports, f)
+	}
+
+	for _, value := range []string{"k8s.io/api", ":staging", "k8s.io/api:", "k8s.io/api:../api", "k8s.io/api:/abs"} {
+		_, err := ParseMapping(value)
+		assert.NotNil(err, value)
+	}
+}
+
+func TestDumpFlat(t *testing.T) {
+	assert := require.New(t)
+
+	c := &Conf{
+		Package: "github.com/example/project",
+		Imports: []Import{
+			{Package: "github.com/foo/a", Version: "v1.0.0", Options: Options{Transitive: true}},
+			{Package: "github.com/foo/b", Version: "^2.0", Repo: "https://example.com/b.git", Options: Options{Test: true}},
+			{Package: "k8s.io/kubernetes", Version: "v1.10.0", Options: Options{Mappings: []Mapping{{"k8s.io/api", "staging/src/k8s.io/api"}}}},
+		},
+		Overrides:        []Import{{Package: "github.com/foo/c", Version: "v3.0.0"}, {Package: "github.com/foo/d", Version: "v1.0.0", Repo: "https://example.com/d.git"}},
+		IgnoreTransitive: []string{"golang.org/x/*"},
+		Packages:         []string{"github.com/foo/a/keep"},
+		Excludes:         []string{"github.com/foo/a/examples"},
+	}
+	c.Dedupe()
+	f := writeTemp(t, "vendor.conf", "")
+	defer os.RemoveAll(filepath.Dir(f))
+	assert.Nil(c.Dump(f))
+
+	data, err := ioutil.ReadFile(f)
+	assert.Nil(err)
+	headers := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			assert.False(headers[line], "%s is written once", line)
+			headers[line] = true
+		}
+	}
+	assert.Len(headers, 6)
+
+	parsed, err := Parse(f)
+	assert.Nil(err)
+	assert.Equal(c.Package, parsed.Package)
+	assert.Equal(c.Imports, parsed.Imports)
+	assert.Equal(c.Overrides, parsed.Overrides)
+	assert.Equal(c.IgnoreTransitive, parsed.IgnoreTransitive)
+	assert.Equal(c.Packages, parsed.Packages)
+	assert.Equal(c.Excludes, parsed.Excludes)
+}
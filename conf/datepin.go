@@ -0,0 +1,34 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// IsDatePin tells if the version pins a branch at a date, like "@2019-03-01" or "master@2019-03-01"
+func IsDatePin(version string) bool {
+	at := strings.LastIndex(version, "@")
+	return at >= 0 && len(version) > at+1 && version[at+1] >= '0' && version[at+1] <= '9'
+}
+
+// ParseDatePin splits a date pin into the branch (empty for the default branch) and the time the newest commit can have.
+// A date without a time is the end of that day, UTC.
+func ParseDatePin(version string) (branch string, before time.Time, err error) {
+	at := strings.LastIndex(version, "@")
+	if at < 0 {
+		return "", before, fmt.Errorf("'%s' is not a date pin: expected [branch]@date", version)
+	}
+	branch, date := version[:at], version[at+1:]
+	for _, layout := range dateLayouts {
+		if before, err = time.Parse(layout, date); err == nil {
+			if layout == "2006-01-02" {
+				before = before.Add(24*time.Hour - time.Second)
+			}
+			return branch, before, nil
+		}
+	}
+	return "", before, fmt.Errorf("invalid date in '%s': expected YYYY-MM-DD or an RFC 3339 time", version)
+}
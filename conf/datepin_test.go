@@ -0,0 +1,31 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDatePin(t *testing.T) {
+	assert := require.New(t)
+
+	assert.True(IsDatePin("@2019-03-01"))
+	assert.True(IsDatePin("release-1.2@2019-03-01"))
+	assert.False(IsDatePin("v1.2.0"))
+	assert.False(IsDatePin("master"))
+	assert.False(IsDatePin("user@host"))
+
+	branch, before, err := ParseDatePin("@2019-03-01")
+	assert.Nil(err)
+	assert.Equal("", branch)
+	assert.Equal(time.Date(2019, 3, 1, 23, 59, 59, 0, time.UTC), before)
+
+	branch, before, err = ParseDatePin("master@2019-03-01T12:30:00+02:00")
+	assert.Nil(err)
+	assert.Equal("master", branch)
+	assert.Equal(time.Date(2019, 3, 1, 10, 30, 0, 0, time.UTC), before.UTC())
+
+	_, _, err = ParseDatePin("master@2019-13-01")
+	assert.NotNil(err)
+}
@@ -0,0 +1,121 @@
+package conf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/rancher/trash/semver"
+)
+
+// parseGopkgToml maps dep's Gopkg.toml onto a conf: [[constraint]] entries are imports, [[override]] entries overrides,
+// ignored packages are excludes and required packages are kept (package= entries). dep's versions are caret ranges
+// unless they have an operator, like trash's ranges.
+func parseGopkgToml(path string, file io.Reader) (*Conf, error) {
+	tables, err := readTOML(path, file)
+	if err != nil {
+		return nil, err
+	}
+	trashConf := &Conf{confFile: path, foreign: true}
+	for _, t := range tables {
+		switch t.Name {
+		case "":
+			for _, p := range t.Lists["ignored"] {
+				if strings.Contains(p, "*") {
+					trashConf.untranslatable("ignored '%s' in %s is not honored: excludes can't have wildcards", p, path)
+					continue
+				}
+				trashConf.Excludes = append(trashConf.Excludes, p)
+			}
+			trashConf.Packages = append(trashConf.Packages, t.Lists["required"]...)
+		case "constraint":
+			i, err := depImport(path, t)
+			if err != nil {
+				return nil, err
+			}
+			trashConf.Imports = append(trashConf.Imports, i)
+		case "override":
+			o, err := depImport(path, t)
+			if err != nil {
+				return nil, err
+			}
+			trashConf.Overrides = append(trashConf.Overrides, o)
+		}
+	}
+	trashConf.Dedupe()
+	return trashConf, nil
+}
+
+// parseGopkgLock reads the [[projects]] of dep's Gopkg.lock as imports pinned to their revisions
+func parseGopkgLock(path string, file io.Reader) (*Conf, error) {
+	tables, err := readTOML(path, file)
+	if err != nil {
+		return nil, err
+	}
+	trashConf := &Conf{confFile: path, foreign: true}
+	for _, t := range tables {
+		if t.Name != "projects" {
+			continue
+		}
+		i := Import{Package: t.Values["name"], Version: t.Values["revision"], Repo: depSource(t.Values["source"])}
+		if i.Package == "" || i.Version == "" {
+			return nil, fmt.Errorf("project without a name or revision in %s: %v", path, t.Values)
+		}
+		trashConf.Imports = append(trashConf.Imports, i)
+	}
+	trashConf.Dedupe()
+	return trashConf, nil
+}
+
+func readTOML(path string, file io.Reader) ([]TOMLTable, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := ParseTOML(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", path, err)
+	}
+	return tables, nil
+}
+
+// depImport maps a [[constraint]] or [[override]]: a project without a version follows the default branch
+func depImport(path string, t TOMLTable) (Import, error) {
+	i := Import{Package: t.Values["name"], Repo: depSource(t.Values["source"])}
+	if i.Package == "" {
+		return i, fmt.Errorf("[[%s]] without a name in %s", t.Name, path)
+	}
+	switch {
+	case t.Values["revision"] != "":
+		i.Version = t.Values["revision"]
+	case t.Values["branch"] != "":
+		i.Version = t.Values["branch"]
+	case t.Values["version"] != "":
+		i.Version = depVersion(t.Values["version"])
+	case t.Name == "constraint":
+		logrus.Warnf("'%s' has no version in %s: following its default branch", i.Package, path)
+		i.Version = "HEAD"
+	}
+	return i, nil
+}
+
+// depVersion turns dep's version into trash's: a bare semantic version is a caret range, and ranges lose their spaces
+func depVersion(version string) string {
+	version = strings.Join(strings.Fields(version), "")
+	if _, err := semver.Parse(version); err == nil {
+		return "^" + version
+	}
+	return version
+}
+
+// depSource is the git URL of a dep source, which can be an import path
+func depSource(source string) string {
+	if source == "" || strings.Contains(source, "://") || strings.Contains(source, "@") || filepath.IsAbs(source) {
+		return source
+	}
+	return "https://" + source
+}
@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGopkgToml(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "Gopkg.toml", `required = ["github.com/foo/tool/cmd/gen"]
+ignored = ["github.com/foo/a/examples", "github.com/foo/x*"]
+
+[[constraint]]
+  name = "github.com/foo/a"
+  version = "1.2.0"
+
+[[constraint]]
+  name = "github.com/foo/b"
+  branch = "develop"
+  source = "github.com/fork/b"
+
+[[constraint]]
+  name = "github.com/foo/c"
+  version = ">= 1.0, < 2.0"
+
+[[constraint]]
+  name = "github.com/foo/d"
+  revision = "0123456789abcdef0123456789abcdef01234567"
+
+[[constraint]]
+  name = "github.com/foo/e"
+
+[[override]]
+  name = "github.com/foo/f"
+  version = "=0.3.1"
+  source = "https://example.com/f.git"
+
+[prune]
+  go-tests = true
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	c, err := Parse(f)
+	assert.Nil(err)
+	assert.Equal([]Import{
+		{Package: "github.com/foo/a", Version: "^1.2.0"},
+		{Package: "github.com/foo/b", Version: "develop", Repo: "https://github.com/fork/b"},
+		{Package: "github.com/foo/c", Version: ">=1.0,<2.0"},
+		{Package: "github.com/foo/d", Version: "0123456789abcdef0123456789abcdef01234567"},
+		{Package: "github.com/foo/e", Version: "HEAD"},
+	}, c.Imports)
+	assert.Equal([]Import{{Package: "github.com/foo/f", Version: "=0.3.1", Repo: "https://example.com/f.git"}}, c.Overrides)
+	assert.Equal([]string{"github.com/foo/a/examples"}, c.Excludes)
+	assert.Equal([]string{"github.com/foo/tool/cmd/gen"}, c.Packages)
+	assert.Len(c.Untranslated(), 1)
+	assert.Contains(c.Untranslated()[0], "github.com/foo/x*")
+
+	assert.NotNil(c.Dump(f))
+	vendorConf := filepath.Join(filepath.Dir(f), "vendor.conf")
+	assert.Nil(c.Dump(vendorConf), "other tools' confs can be written as trash confs")
+	flat, err := Parse(vendorConf)
+	assert.Nil(err)
+	assert.Equal(c.Imports, flat.Imports)
+	assert.Equal(c.Overrides, flat.Overrides)
+	assert.Equal(c.Packages, flat.Packages)
+
+	findings, err := (&Linter{}).Lint(f)
+	assert.Nil(err)
+	assert.Len(findings, 2)
+	assert.Contains(findings[0].Message, "branch 'HEAD'")
+	assert.Contains(findings[1].Message, "package=github.com/foo/tool/cmd/gen is not vendored")
+}
+
+func TestParseGopkgLockAndGodeps(t *testing.T) {
+	assert := require.New(t)
+
+	lock := writeTemp(t, "Gopkg.lock", `[[projects]]
+  name = "github.com/foo/a"
+  packages = ["."]
+  revision = "0123456789abcdef0123456789abcdef01234567"
+  version = "v1.2.0"
+`)
+	defer os.RemoveAll(filepath.Dir(lock))
+	c, err := Parse(lock)
+	assert.Nil(err)
+	assert.Equal([]Import{{Package: "github.com/foo/a", Version: "0123456789abcdef0123456789abcdef01234567"}}, c.Imports)
+
+	godeps := writeTemp(t, "Godeps.json", `{
+	"ImportPath": "github.com/example/project",
+	"Deps": [
+		{"ImportPath": "github.com/foo/b/sub", "Rev": "89abcdef0123456789abcdef0123456789abcdef"},
+		{"ImportPath": "github.com/foo/b", "Rev": "89abcdef0123456789abcdef0123456789abcdef"}
+	]
+}`)
+	defer os.RemoveAll(filepath.Dir(godeps))
+	c, err = Parse(godeps)
+	assert.Nil(err)
+	assert.Equal("github.com/example/project", c.Package)
+	assert.Equal([]Import{{Package: "github.com/foo/b", Version: "89abcdef0123456789abcdef0123456789abcdef"}}, c.Imports)
+}
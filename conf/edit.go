@@ -0,0 +1,221 @@
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Add adds a new import and saves the conf file, keeping its format (and comments for flat files)
+func (t *Conf) Add(i Import) error {
+	if i.Package == "" || i.Version == "" {
+		return fmt.Errorf("both package and version are needed to add an import, got: '%s' '%s'", i.Package, i.Version)
+	}
+	if _, ok := t.ImportMap[i.Package]; ok {
+		return fmt.Errorf("package '%s' is already imported (in %s)", i.Package, t.confFile)
+	}
+	t.Imports = append(t.Imports, i)
+	t.Dedupe()
+	if t.yamlType {
+		return t.Dump(t.confFile)
+	}
+	return t.editLines(func(lines []string, root int, imports []int) []string {
+		// after the imports, or the root package if there are none, not among the other entries
+		at := len(lines)
+		if len(imports) > 0 {
+			at = imports[len(imports)-1] + 1
+		} else if root >= 0 {
+			at = root + 1
+		}
+		for _, n := range imports {
+			if fields := strings.Fields(stripComment(lines[n])); fields[0] > i.Package {
+				at = n
+				break
+			}
+		}
+		line := formatImport(lines, imports, i)
+		return append(lines[:at], append([]string{line}, lines[at:]...)...)
+	})
+}
+
+// Remove removes an import (with any duplicates) and saves the conf file
+func (t *Conf) Remove(pkg string) error {
+	if _, ok := t.ImportMap[pkg]; !ok {
+		return fmt.Errorf("package '%s' is not imported (in %s)", pkg, t.confFile)
+	}
+	imports := []Import{}
+	for _, i := range t.Imports {
+		if i.Package != pkg {
+			imports = append(imports, i)
+		}
+	}
+	t.Imports = imports
+	t.Dedupe()
+	if t.yamlType {
+		return t.Dump(t.confFile)
+	}
+	return t.editLines(func(lines []string, _ int, imports []int) []string {
+		r := []string{}
+		next := 0
+		for _, n := range imports {
+			if strings.Fields(stripComment(lines[n]))[0] == pkg {
+				r = append(r, lines[next:n]...)
+				next = n + 1
+			}
+		}
+		return append(r, lines[next:]...)
+	})
+}
+
+// SetVersion changes the version of an import and saves the conf file
+func (t *Conf) SetVersion(pkg, version string) error {
+	i, ok := t.ImportMap[pkg]
+	if !ok {
+		return fmt.Errorf("package '%s' is not imported (in %s)", pkg, t.confFile)
+	}
+	if version == "" {
+		return fmt.Errorf("empty version for package '%s'", pkg)
+	}
+	i.Version = version
+	t.ImportMap[pkg] = i
+	for k := range t.Imports {
+		if t.Imports[k].Package == pkg {
+			t.Imports[k].Version = version
+		}
+	}
+	if t.yamlType {
+		return t.Dump(t.confFile)
+	}
+	return t.editLines(func(lines []string, _ int, imports []int) []string {
+		for _, n := range imports {
+			spans := fieldSpans(lines[n])
+			if lines[n][spans[0][0]:spans[0][1]] != pkg {
+				continue
+			}
+			if len(spans) < 2 || strings.Contains(lines[n][spans[1][0]:spans[1][1]], "=") {
+				// no version yet: insert it right after the package
+				lines[n] = lines[n][:spans[0][1]] + "\t" + version + lines[n][spans[0][1]:]
+				continue
+			}
+			lines[n] = lines[n][:spans[1][0]] + version + lines[n][spans[1][1]:]
+		}
+		return lines
+	})
+}
+
+// editLines lets edit modify the lines of a flat conf file. It gets the indices of the root package line (-1 if there's none)
+// and of the import lines, which parse tells apart the same way.
+func (t *Conf) editLines(edit func(lines []string, root int, imports []int) []string) error {
+	data, err := ioutil.ReadFile(t.confFile)
+	if err != nil {
+		return err
+	}
+	content := strings.TrimSuffix(string(data), "\n")
+	lines := []string{}
+	if content != "" {
+		lines = strings.Split(content, "\n")
+	}
+	imports := []int{}
+	root := -1
+	rootPackage := ""
+	for n, line := range lines {
+		fields := strings.Fields(stripComment(line))
+		switch {
+		case len(fields) == 0:
+		case isRootPackageLine(fields, rootPackage):
+			root, rootPackage = n, fields[0]
+		case isImportLine(fields):
+			imports = append(imports, n)
+		}
+	}
+	lines = edit(lines, root, imports)
+	return ioutil.WriteFile(t.confFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+func stripComment(line string) string {
+	if commentStart := strings.Index(line, "#"); commentStart >= 0 {
+		return line[0:commentStart]
+	}
+	return line
+}
+
+// fieldSpans returns the start and end offsets of the fields in line, ignoring the comment
+func fieldSpans(line string) [][2]int {
+	spans := [][2]int{}
+	line = stripComment(line)
+	start := -1
+	for k, c := range line {
+		if c == ' ' || c == '\t' {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, k})
+				start = -1
+			}
+		} else if start < 0 {
+			start = k
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(line)})
+	}
+	return spans
+}
+
+// formatImport formats the import line aligning the columns with the existing import lines, if they are aligned with spaces
+func formatImport(lines []string, imports []int, i Import) string {
+	fields := []string{i.Package, i.Version}
+	if i.Repo != "" {
+		fields = append(fields, i.Repo)
+	}
+	if options := formatOptions(i.Options); options != "" {
+		fields = append(fields, options)
+	}
+	columns := []int{}
+	for _, n := range imports {
+		if strings.Contains(lines[n], "\t") {
+			continue
+		}
+		for k, span := range fieldSpans(lines[n]) {
+			if k == 0 {
+				continue
+			}
+			if len(columns) < k {
+				columns = append(columns, 0)
+			}
+			if span[0] > columns[k-1] {
+				columns[k-1] = span[0]
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return strings.Join(fields, "\t")
+	}
+	line := fields[0]
+	for k, f := range fields[1:] {
+		pad := 1
+		if k < len(columns) && columns[k] > len(line) {
+			pad = columns[k] - len(line)
+		}
+		line += strings.Repeat(" ", pad) + f
+	}
+	return line
+}
+
+func formatOptions(o Options) string {
+	options := []string{}
+	if o.Transitive {
+		options = append(options, "transitive=true")
+	}
+	if o.Staging {
+		options = append(options, "staging=true")
+	}
+	if o.IgnoreExcludes {
+		options = append(options, "ignore-excludes=true")
+	}
+	if o.Test {
+		options = append(options, "test=true")
+	}
+	for _, m := range o.Mappings {
+		options = append(options, "map="+m.Package+":"+m.Dir)
+	}
+	return strings.Join(options, ",")
+}
@@ -0,0 +1,147 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const flatConf = `# package
+github.com/rancher/trash
+
+github.com/foo/a                  v1.0.0   # we need 1.0
+github.com/foo/c                  v3.0.0   https://example.com/c.git
+
+-github.com/foo/c/examples
+`
+
+func TestEditFlat(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "vendor.conf", flatConf)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	c, err := Parse(f)
+	assert.Nil(err)
+
+	assert.Nil(c.Add(Import{Package: "github.com/foo/b", Version: "v2.0.0", Options: Options{Transitive: true}}))
+	assert.NotNil(c.Add(Import{Package: "github.com/foo/b", Version: "v2.0.1"}))
+	assert.Nil(c.Add(Import{Package: "github.com/foo/t", Version: "v1.1.3", Options: Options{Test: true}}))
+	assert.Nil(c.SetVersion("github.com/foo/a", "v1.1.0"))
+	assert.Nil(c.Remove("github.com/foo/c"))
+	assert.NotNil(c.Remove("github.com/foo/c"))
+
+	data, err := ioutil.ReadFile(f)
+	assert.Nil(err)
+	assert.Equal(`# package
+github.com/rancher/trash
+
+github.com/foo/a                  v1.1.0   # we need 1.0
+github.com/foo/b                  v2.0.0   transitive=true
+github.com/foo/t                  v1.1.3   test=true
+
+-github.com/foo/c/examples
+`, string(data))
+
+	c, err = Parse(f)
+	assert.Nil(err)
+	assert.Len(c.Imports, 3)
+	b, ok := c.Get("github.com/foo/b")
+	assert.True(ok)
+	assert.True(b.Transitive)
+	test, _ := c.Get("github.com/foo/t")
+	assert.True(test.Test)
+	assert.Equal([]string{"github.com/foo/c/examples"}, c.Excludes)
+}
+
+func TestEditFlatEntries(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "vendor.conf", `ignore-transitive=golang.org/x/*
+
+github.com/rancher/trash
+
+github.com/foo/a v1.0.0
+
+override=github.com/foo/o v2.0.0
+override=github.com/foo/p v1.0.0 https://example.com/p.git
+package=github.com/foo/a/keep
+-github.com/foo/a/examples
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	c, err := Parse(f)
+	assert.Nil(err)
+	assert.Equal("github.com/rancher/trash", c.Package)
+	assert.Nil(c.Add(Import{Package: "github.com/foo/z", Version: "v1.0.0"}))
+	assert.NotNil(c.SetVersion("github.com/foo/o", "v3.0.0"), "overrides are not imports")
+	assert.NotNil(c.Remove("github.com/foo/o"))
+
+	data, err := ioutil.ReadFile(f)
+	assert.Nil(err)
+	assert.Equal(`ignore-transitive=golang.org/x/*
+
+github.com/rancher/trash
+
+github.com/foo/a v1.0.0
+github.com/foo/z v1.0.0
+
+override=github.com/foo/o v2.0.0
+override=github.com/foo/p v1.0.0 https://example.com/p.git
+package=github.com/foo/a/keep
+-github.com/foo/a/examples
+`, string(data))
+
+	assert.Nil(c.Remove("github.com/foo/a"))
+	assert.Nil(c.Remove("github.com/foo/z"))
+	assert.Nil(c.Add(Import{Package: "github.com/foo/b", Version: "v2.0.0"}))
+	data, err = ioutil.ReadFile(f)
+	assert.Nil(err)
+	assert.Equal(`ignore-transitive=golang.org/x/*
+
+github.com/rancher/trash
+github.com/foo/b	v2.0.0
+
+
+override=github.com/foo/o v2.0.0
+override=github.com/foo/p v1.0.0 https://example.com/p.git
+package=github.com/foo/a/keep
+-github.com/foo/a/examples
+`, string(data), "with no imports left, it goes after the root package")
+
+	c, err = Parse(f)
+	assert.Nil(err)
+	assert.Equal("github.com/rancher/trash", c.Package)
+	assert.Equal([]Import{{Package: "github.com/foo/b", Version: "v2.0.0"}}, c.Imports)
+	assert.Equal([]string{"golang.org/x/*"}, c.IgnoreTransitive)
+	assert.Len(c.Overrides, 2)
+	assert.Equal([]string{"github.com/foo/a/keep"}, c.Packages)
+}
+
+func TestEditYaml(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "trash.yml", `package: github.com/rancher/trash
+import:
+- package: github.com/foo/a
+  version: v1.0.0
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	c, err := Parse(f)
+	assert.Nil(err)
+	assert.Nil(c.Add(Import{Package: "github.com/foo/b", Version: "v2.0.0", Repo: "https://example.com/b.git"}))
+	assert.Nil(c.SetVersion("github.com/foo/a", "v1.1.0"))
+
+	c, err = Parse(f)
+	assert.Nil(err)
+	assert.True(c.yamlType)
+	assert.Len(c.Imports, 2)
+	a, _ := c.Get("github.com/foo/a")
+	assert.Equal("v1.1.0", a.Version)
+	b, _ := c.Get("github.com/foo/b")
+	assert.Equal("https://example.com/b.git", b.Repo)
+}
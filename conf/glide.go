@@ -0,0 +1,88 @@
+package conf
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	yaml "github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// glideYaml is glide's glide.yaml, along with the trash conf keys older trash glide.yaml files may have
+type glideYaml struct {
+	Package     string        `yaml:"package"`
+	Ignore      []string      `yaml:"ignore"`
+	ExcludeDirs []string      `yaml:"excludeDirs"`
+	Imports     []glideImport `yaml:"import"`
+	TestImports []glideImport `yaml:"testImport"`
+	Excludes    []string      `yaml:"exclude"`
+	Packages    []string      `yaml:"packages"`
+}
+
+type glideImport struct {
+	Package     string   `yaml:"package"`
+	Version     string   `yaml:"version"`
+	Ref         string   `yaml:"ref"`
+	Repo        string   `yaml:"repo"`
+	Vcs         string   `yaml:"vcs"`
+	Subpackages []string `yaml:"subpackages"`
+	Os          []string `yaml:"os"`
+	Arch        []string `yaml:"arch"`
+	Options     `yaml:",inline"`
+}
+
+// IsGlideFile tells if the conf file is glide's
+func IsGlideFile(path string) bool {
+	name := filepath.Base(path)
+	return name == "glide.yaml" || name == "glide.yml"
+}
+
+// parseGlide maps glide.yaml onto a conf: subpackages are kept packages (package= entries), ignored packages are excludes,
+// and test imports are test-only imports (the test option, which --no-test leaves out). It warns about what trash can't do:
+// other VCS than git, os and arch filters, and excludeDirs.
+func parseGlide(path string, file io.Reader) (*Conf, error) {
+	g := glideYaml{}
+	if err := yaml.NewDecoder(file).Decode(&g); err != nil {
+		return nil, err
+	}
+	trashConf := &Conf{Package: g.Package, confFile: path, yamlType: true, foreign: true}
+	trashConf.Excludes = append(g.Excludes, g.Ignore...)
+	trashConf.Packages = g.Packages
+	for _, d := range g.ExcludeDirs {
+		trashConf.untranslatable("excludeDirs '%s' in %s is not honored: trash looks at the imports of all of the project's dirs", d, path)
+	}
+	for _, gi := range g.Imports {
+		trashConf.Imports = append(trashConf.Imports, gi.toImport(trashConf, false))
+	}
+	for _, gi := range g.TestImports {
+		trashConf.Imports = append(trashConf.Imports, gi.toImport(trashConf, true))
+	}
+	trashConf.Dedupe()
+	return trashConf, nil
+}
+
+func (gi glideImport) toImport(trashConf *Conf, test bool) Import {
+	i := Import{Package: gi.Package, Version: gi.Version, Repo: gi.Repo, Options: gi.Options}
+	if i.Version == "" {
+		i.Version = gi.Ref
+	}
+	i.Test = i.Test || test
+	for _, sub := range gi.Subpackages {
+		trashConf.Packages = append(trashConf.Packages, path.Join(gi.Package, strings.TrimPrefix(sub, "/")))
+	}
+	if gi.Vcs != "" && gi.Vcs != "git" {
+		trashConf.untranslatable("'%s' uses %s in %s: trash only supports git, trying it anyway", gi.Package, gi.Vcs, trashConf.confFile)
+	}
+	filters := []string{}
+	if len(gi.Os) > 0 {
+		filters = append(filters, "os "+strings.Join(gi.Os, ", "))
+	}
+	if len(gi.Arch) > 0 {
+		filters = append(filters, "arch "+strings.Join(gi.Arch, ", "))
+	}
+	if len(filters) > 0 {
+		trashConf.untranslatable("'%s' is only for %s in %s: trash vendors it for all platforms", gi.Package, strings.Join(filters, " and "), trashConf.confFile)
+	}
+	return i
+}
@@ -0,0 +1,85 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const glideConf = `package: github.com/example/project
+homepage: https://example.com
+ignore:
+- appengine
+excludeDirs:
+- fixtures
+import:
+- package: github.com/foo/a
+  version: ^1.2.0
+  subpackages:
+  - client
+  - /server
+- package: github.com/foo/b
+  ref: 0123456789abcdef0123456789abcdef01234567
+  repo: https://github.com/fork/b.git
+  vcs: git
+- package: github.com/foo/c
+  version: v1.0.0
+  os:
+  - windows
+- package: github.com/foo/d
+  version: v2.0.0
+  transitive: true
+testImport:
+- package: github.com/stretchr/testify
+  version: v1.1.3
+  subpackages:
+  - require
+`
+
+func TestParseGlide(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "glide.yaml", glideConf)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	c, err := Parse(f)
+	assert.Nil(err)
+	assert.Equal("github.com/example/project", c.Package)
+	assert.Equal([]Import{
+		{Package: "github.com/foo/a", Version: "^1.2.0"},
+		{Package: "github.com/foo/b", Version: "0123456789abcdef0123456789abcdef01234567", Repo: "https://github.com/fork/b.git"},
+		{Package: "github.com/foo/c", Version: "v1.0.0"},
+		{Package: "github.com/foo/d", Version: "v2.0.0", Options: Options{Transitive: true}},
+		{Package: "github.com/stretchr/testify", Version: "v1.1.3", Options: Options{Test: true}},
+	}, c.Imports)
+	assert.Equal([]string{"appengine"}, c.Excludes)
+	assert.Equal([]string{"github.com/foo/a/client", "github.com/foo/a/server", "github.com/stretchr/testify/require"}, c.Packages)
+	assert.Len(c.Untranslated(), 2)
+	assert.Contains(c.Untranslated()[0], "excludeDirs 'fixtures'")
+	assert.Contains(c.Untranslated()[1], "only for os windows")
+
+	assert.NotNil(c.Dump(f))
+	data, err := Parse(f)
+	assert.Nil(err)
+	assert.Len(data.Imports, 5, "the failed dump left glide.yaml alone")
+}
+
+func TestLintGlide(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "glide.yaml", glideConf+"- package: github.com/foo/e\n  flatten: true\n")
+	defer os.RemoveAll(filepath.Dir(f))
+
+	findings, err := (&Linter{}).Lint(f)
+	assert.Nil(err)
+	for _, finding := range findings {
+		t.Log(finding)
+	}
+	assert.Len(findings, 2)
+	assert.Equal(29, findings[0].Line)
+	assert.Contains(findings[0].Message, "unknown option 'flatten'")
+	assert.Equal(29, findings[1].Line)
+	assert.Contains(findings[1].Message, "version not specified")
+}
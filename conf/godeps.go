@@ -0,0 +1,29 @@
+package conf
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Masterminds/glide/godep"
+	glideutil "github.com/Masterminds/glide/util"
+)
+
+// parseGodeps reads godep's Godeps.json, which lists packages, as imports of their repos pinned to their revisions
+func parseGodeps(path string, file io.Reader) (*Conf, error) {
+	g := godep.Godeps{}
+	if err := json.NewDecoder(file).Decode(&g); err != nil {
+		return nil, err
+	}
+	trashConf := &Conf{Package: g.ImportPath, confFile: path, foreign: true}
+	seen := map[string]bool{}
+	for _, d := range g.Deps {
+		pkg, _ := glideutil.NormalizeName(d.ImportPath)
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		trashConf.Imports = append(trashConf.Imports, Import{Package: pkg, Version: d.Rev})
+	}
+	trashConf.Dedupe()
+	return trashConf, nil
+}
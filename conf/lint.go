@@ -0,0 +1,471 @@
+package conf
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "github.com/cloudfoundry-incubator/candiedyaml"
+
+	"github.com/rancher/trash/semver"
+)
+
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Finding is a problem found in a conf file
+type Finding struct {
+	File     string
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Severity, f.Message)
+}
+
+// Linter checks conf files for problems Parse silently lets through.
+// The hooks let it look at the vendor dir and the repo cache: checks that need them are skipped if they're nil.
+type Linter struct {
+	// Exists tells if the package dir is present in the vendor dir or in the repo cache
+	Exists func(pkg string) bool
+	// IsBranch tells if the import's version is a branch of its repo
+	IsBranch func(i Import) bool
+}
+
+// entry is a conf line as it was written, before Dedupe
+type entry struct {
+	Import
+	line int
+}
+
+type lintState struct {
+	file     string
+	findings []Finding
+	imports  []entry
+	excludes map[string]int
+	packages map[string]int
+	// overrides are checked on their own: they don't import anything
+	overrides []entry
+}
+
+func (s *lintState) add(line int, severity Severity, format string, args ...interface{}) {
+	s.findings = append(s.findings, Finding{File: s.file, Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+var (
+	knownOptions   = map[string]bool{"transitive": true, "staging": true, "ignore-excludes": true, "test": true, "map": true}
+	abbreviatedSHA = regexp.MustCompile(`^[0-9a-f]{7,39}$`)
+	yamlPackage    = regexp.MustCompile(`^\s*-?\s*package:`)
+	topKey         = regexp.MustCompile(`^[a-zA-Z-]+:`)
+	// glide.yaml's keys, which the glide.yaml adapter reads (or warns about)
+	glideKeys       = map[string]bool{"ignore": true, "excludeDirs": true, "testImport": true, "homepage": true, "license": true, "owners": true, "description": true}
+	glideImportKeys = map[string]bool{"vcs": true, "subpackages": true, "os": true, "arch": true}
+)
+
+// Lint checks the conf file at path and returns the findings sorted by line
+func (l *Linter) Lint(path string) ([]Finding, error) {
+	t, err := Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &lintState{file: path, excludes: map[string]int{}, packages: map[string]int{}}
+	switch {
+	case t.yamlType:
+		err = s.scanYaml(path)
+	case t.foreign:
+		// other tools' files are checked as trash reads them, without lines
+		for _, i := range t.Imports {
+			s.imports = append(s.imports, entry{i, 0})
+		}
+		for _, e := range t.Excludes {
+			s.excludes[e] = 0
+		}
+		for _, p := range t.Packages {
+			s.packages[p] = 0
+		}
+	default:
+		err = s.scanFlat(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.check(s)
+	sort.Stable(byLine(s.findings))
+	return s.findings, nil
+}
+
+func (s *lintState) scanFlat(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rootPackage := ""
+	scanner := bufio.NewScanner(bufio.NewReader(file))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if commentStart := strings.Index(line, "#"); commentStart >= 0 {
+			line = line[0:commentStart]
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		if len(fields) == 1 && rootPackage == "" {
+			rootPackage = fields[0]
+			continue
+		}
+		if fields[0][0] == '-' {
+			if len(fields) > 1 {
+				s.add(n, Error, "malformed exclude: expected a single path, got %d fields", len(fields))
+			}
+			if e := strings.TrimSpace(fields[0][1:]); e == "" {
+				s.add(n, Error, "malformed exclude: empty path")
+			} else {
+				s.excludes[e] = n
+			}
+			continue
+		}
+		if strings.HasPrefix(fields[0], "package=") {
+			if len(fields) > 1 {
+				s.add(n, Error, "malformed package= entry: expected a single package, got %d fields", len(fields))
+			}
+			if p := strings.TrimPrefix(fields[0], "package="); p == "" {
+				s.add(n, Error, "malformed package= entry: empty package")
+			} else {
+				s.packages[p] = n
+			}
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "ignore-transitive=") {
+			if len(fields) > 1 {
+				s.add(n, Error, "malformed ignore-transitive= entry: expected a single pattern, got %d fields", len(fields))
+			}
+			if p := strings.TrimPrefix(fields[0], "ignore-transitive="); p == "" {
+				s.add(n, Error, "malformed ignore-transitive= entry: empty pattern")
+			} else {
+				s.checkPattern(n, p)
+			}
+			continue
+		}
+		if strings.HasPrefix(fields[0], "override=") {
+			o := Import{Package: strings.TrimPrefix(fields[0], "override=")}
+			if len(fields) > 3 {
+				s.add(n, Error, "malformed override: too many fields, expected `override=package version [repo]`")
+			}
+			if len(fields) > 1 {
+				o.Version = fields[1]
+			}
+			if len(fields) > 2 {
+				o.Repo = fields[2]
+			}
+			s.overrides = append(s.overrides, entry{o, n})
+			continue
+		}
+
+		i := Import{Package: fields[0]}
+		if len(fields) > 4 {
+			s.add(n, Error, "malformed import: too many fields, expected `package version [repo] [options]`")
+		}
+		if len(fields) > 3 {
+			s.checkOptions(n, fields[3])
+		}
+		if len(fields) > 2 {
+			if strings.Contains(fields[2], "=") {
+				if len(fields) > 3 {
+					s.add(n, Error, "malformed import: options must be the last field")
+				}
+				s.checkOptions(n, fields[2])
+			} else {
+				i.Repo = fields[2]
+			}
+		}
+		if len(fields) > 1 {
+			i.Version = fields[1]
+			if strings.Contains(i.Version, "=") && !semver.IsConstraint(i.Version) {
+				s.add(n, Error, "malformed import: options '%s' in place of the version", i.Version)
+			}
+		}
+		s.imports = append(s.imports, entry{i, n})
+	}
+	return scanner.Err()
+}
+
+func (s *lintState) checkOptions(n int, options string) {
+	for _, part := range strings.Split(options, ",") {
+		kvParts := strings.SplitN(part, "=", 2)
+		if len(kvParts) != 2 || kvParts[0] == "" {
+			s.add(n, Error, "malformed option '%s': expected key=value", part)
+			continue
+		}
+		if !knownOptions[kvParts[0]] {
+			s.add(n, Warning, "unknown option '%s' is ignored", kvParts[0])
+			continue
+		}
+		if kvParts[0] == "map" {
+			if _, err := ParseMapping(kvParts[1]); err != nil {
+				s.add(n, Error, "%s", err)
+			}
+			continue
+		}
+		if kvParts[1] != "true" && kvParts[1] != "false" {
+			s.add(n, Error, "option '%s' must be true or false, got '%s'", kvParts[0], kvParts[1])
+		}
+	}
+}
+
+// checkYamlMappings checks the map: list of a YAML import: mappings with a package and a dir
+func (s *lintState) checkYamlMappings(n int, v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		s.add(n, Error, "option 'map' must be a list of mappings with a package and a dir")
+		return
+	}
+	for _, item := range list {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			s.add(n, Error, "malformed mapping '%v': expected a package and a dir", item)
+			continue
+		}
+		if _, err := ParseMapping(fmt.Sprintf("%v:%v", m["package"], m["dir"])); err != nil || m["package"] == nil || m["dir"] == nil {
+			s.add(n, Error, "malformed mapping '%v': expected a package and a dir inside the repo", item)
+		}
+	}
+}
+
+func (s *lintState) checkPattern(n int, pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		s.add(n, Error, "malformed ignore-transitive pattern '%s': %s", pattern, err)
+	}
+}
+
+func (s *lintState) scanYaml(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	raw := struct {
+		Imports     []map[string]interface{} `yaml:"import"`
+		TestImports []map[string]interface{} `yaml:"testImport"`
+		Excludes    []string                 `yaml:"exclude"`
+		Packages    []string                 `yaml:"packages"`
+		Override    []map[string]interface{} `yaml:"override"`
+		Ignore      []string                 `yaml:"ignore-transitive"`
+	}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	top := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return err
+	}
+	glide := IsGlideFile(path)
+
+	// YAML decoding loses the positions: find them by scanning the text
+	lines := strings.Split(string(data), "\n")
+	lineOf := func(value string, from int) int {
+		for n := from; n < len(lines); n++ {
+			if strings.Contains(lines[n], value) {
+				return n + 1
+			}
+		}
+		return 0
+	}
+	for k := range top {
+		switch {
+		case k == "package", k == "import", k == "exclude", k == "packages", k == "override", k == "ignore-transitive":
+		case glide && glideKeys[k]:
+		default:
+			s.add(lineOf(k+":", 0), Warning, "unknown key '%s' is ignored", k)
+		}
+	}
+
+	// the lines of the packages in each section
+	packageLines := map[string][]int{}
+	section := ""
+	for n, line := range lines {
+		if strings.HasPrefix(line, "package:") {
+			continue
+		}
+		if topKey.MatchString(line) {
+			section = line[:strings.Index(line, ":")]
+		}
+		if yamlPackage.MatchString(line) {
+			packageLines[section] = append(packageLines[section], n+1)
+		}
+	}
+	scanImports := func(section string, imports []map[string]interface{}) {
+		for k, m := range imports {
+			n := 0
+			if k < len(packageLines[section]) {
+				n = packageLines[section][k]
+			}
+			i := Import{}
+			for key, v := range m {
+				value := fmt.Sprint(v)
+				switch {
+				case key == "package":
+					i.Package = value
+				case key == "version":
+					i.Version = value
+				case key == "repo":
+					i.Repo = value
+				case glide && key == "ref":
+					if i.Version == "" {
+						i.Version = value
+					}
+				case glide && glideImportKeys[key]:
+				case !knownOptions[key]:
+					s.add(n, Warning, "unknown option '%s' is ignored", key)
+				case key == "map":
+					s.checkYamlMappings(n, v)
+				default:
+					if _, ok := v.(bool); !ok {
+						s.add(n, Error, "option '%s' must be true or false, got '%s'", key, value)
+					}
+				}
+			}
+			if i.Package == "" {
+				s.add(n, Error, "malformed import: no package")
+				continue
+			}
+			s.imports = append(s.imports, entry{i, n})
+		}
+	}
+	scanImports("import", raw.Imports)
+	if glide {
+		scanImports("testImport", raw.TestImports)
+	}
+	for _, e := range raw.Excludes {
+		s.excludes[e] = lineOf(e, lineOf("exclude:", 0))
+	}
+	from := lineOf("override:", 0)
+	for _, m := range raw.Override {
+		o := Import{}
+		if p, ok := m["package"]; ok {
+			o.Package = fmt.Sprint(p)
+		}
+		if v, ok := m["version"]; ok {
+			o.Version = fmt.Sprint(v)
+		}
+		if r, ok := m["repo"]; ok {
+			o.Repo = fmt.Sprint(r)
+		}
+		if n := lineOf("package: "+o.Package, from); n > 0 {
+			from = n
+		}
+		s.overrides = append(s.overrides, entry{o, from})
+	}
+	for _, p := range raw.Ignore {
+		s.checkPattern(lineOf(p, lineOf("ignore-transitive:", 0)), p)
+	}
+	for _, p := range raw.Packages {
+		s.packages[p] = lineOf(p, lineOf("packages:", 0))
+	}
+	return nil
+}
+
+func (l *Linter) check(s *lintState) {
+	seen := map[string]entry{}
+	for _, e := range s.imports {
+		if first, ok := seen[e.Package]; ok {
+			if first.Version != e.Version || first.Repo != e.Repo {
+				s.add(e.line, Error, "'%s' is already imported on line %d with a different version or repo: this entry is ignored", e.Package, first.line)
+			} else {
+				s.add(e.line, Warning, "'%s' is already imported on line %d", e.Package, first.line)
+			}
+			continue
+		}
+		seen[e.Package] = e
+
+		switch {
+		case e.Version == "":
+			s.add(e.line, Error, "version not specified for package '%s'", e.Package)
+		case semver.IsConstraint(e.Version):
+			if _, err := semver.ParseConstraint(e.Version); err != nil {
+				s.add(e.line, Error, "%s", err)
+			}
+		case IsDatePin(e.Version):
+			if _, _, err := ParseDatePin(e.Version); err != nil {
+				s.add(e.line, Error, "%s", err)
+			}
+		case e.Version == "master" || e.Version == "HEAD" || e.Version == "default":
+			s.add(e.line, Warning, "'%s' is pinned to branch '%s': what gets vendored changes as the branch moves", e.Package, e.Version)
+		case abbreviatedSHA.MatchString(e.Version):
+			s.add(e.line, Warning, "'%s' is pinned to abbreviated SHA '%s': use the full 40 character SHA", e.Package, e.Version)
+		case l.IsBranch != nil && l.IsBranch(e.Import):
+			s.add(e.line, Warning, "'%s' is pinned to branch '%s': what gets vendored changes as the branch moves", e.Package, e.Version)
+		}
+	}
+
+	overridden := map[string]int{}
+	for _, o := range s.overrides {
+		switch {
+		case o.Package == "":
+			s.add(o.line, Error, "malformed override: no package")
+		case o.Version == "" && o.Repo == "":
+			s.add(o.line, Error, "override of '%s' sets neither a version nor a repo", o.Package)
+		case overridden[o.Package] > 0:
+			s.add(o.line, Error, "'%s' is already overridden on line %d: this override is ignored", o.Package, overridden[o.Package])
+		}
+		if o.Package != "" && overridden[o.Package] == 0 {
+			overridden[o.Package] = o.line
+		}
+	}
+
+	covered := func(p string) bool {
+		for pkg := range seen {
+			if p == pkg || strings.HasPrefix(p, pkg+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	for e, n := range s.excludes {
+		if l.Exists != nil {
+			if !l.Exists(e) {
+				s.add(n, Warning, "exclude '%s' matches nothing", e)
+			}
+			continue
+		}
+		matches := covered(e)
+		for pkg := range seen {
+			matches = matches || strings.HasPrefix(pkg, e+"/")
+		}
+		if !matches {
+			s.add(n, Warning, "exclude '%s' matches no import", e)
+		}
+	}
+	for p, n := range s.packages {
+		if !covered(p) && (l.Exists == nil || !l.Exists(p)) {
+			s.add(n, Error, "package=%s is not vendored", p)
+		}
+	}
+}
+
+type byLine []Finding
+
+func (f byLine) Len() int           { return len(f) }
+func (f byLine) Less(i, j int) bool { return f[i].Line < f[j].Line }
+func (f byLine) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
@@ -0,0 +1,172 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemp(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "trash-conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLintFlat(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "vendor.conf", `# package
+github.com/rancher/trash
+
+github.com/foo/a    v1.0.0
+github.com/foo/b    master
+github.com/foo/c    a1b2c3d
+github.com/foo/a    v1.0.1
+github.com/foo/d    v1 https://example.com/d.git transitive=true,shallow=true
+github.com/foo/e
+github.com/foo/f    v2 transitive=yes
+github.com/foo/g    >=1.2,<2
+github.com/foo/h    ^1.x
+github.com/foo/i    master@2019-13-01
+-github.com/foo/c/examples
+-github.com/bar/x
+package=github.com/baz
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	findings, err := (&Linter{}).Lint(f)
+	assert.Nil(err)
+
+	lines := map[int]Severity{}
+	for _, finding := range findings {
+		t.Log(finding)
+		lines[finding.Line] = finding.Severity
+	}
+	assert.Len(findings, 10)
+	assert.Equal(Warning, lines[5])  // master
+	assert.Equal(Warning, lines[6])  // abbreviated SHA
+	assert.Equal(Error, lines[7])    // conflicting duplicate
+	assert.Equal(Warning, lines[8])  // unknown option
+	assert.Equal(Error, lines[9])    // no version
+	assert.Equal(Error, lines[10])   // not a boolean
+	assert.Equal(Error, lines[12])   // invalid version constraint
+	assert.Equal(Error, lines[13])   // invalid date
+	assert.Equal(Warning, lines[15]) // exclude matches nothing
+	assert.Equal(Error, lines[16])   // package= not vendored
+}
+
+func TestLintYaml(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "trash.yml", `package: github.com/rancher/trash
+import:
+- package: github.com/foo/a
+  version: v1.0.0
+- package: github.com/foo/a
+  version: v1.0.0
+- package: github.com/foo/b
+  version: v2.0.0
+  flatten: true
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	findings, err := (&Linter{}).Lint(f)
+	assert.Nil(err)
+	assert.Len(findings, 2)
+	assert.Equal(5, findings[0].Line)
+	assert.Equal(Warning, findings[0].Severity)
+	assert.Equal(7, findings[1].Line)
+	assert.Contains(findings[1].Message, "flatten")
+}
+
+func TestLintOverrides(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "trash.yml", `package: github.com/rancher/trash
+import:
+- package: github.com/foo/a
+  version: v1.0.0
+override:
+- package: github.com/foo/b
+  version: v1.2.0
+- package: github.com/foo/c
+- package: github.com/foo/b
+  repo: https://example.com/b.git
+ignore-transitive:
+- github.com/foo/[d
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	findings, err := (&Linter{}).Lint(f)
+	assert.Nil(err)
+	for _, finding := range findings {
+		t.Log(finding)
+	}
+	assert.Len(findings, 3)
+	assert.Contains(findings[0].Message, "neither a version nor a repo")
+	assert.Equal(8, findings[0].Line)
+	assert.Contains(findings[1].Message, "already overridden on line 6")
+	assert.Contains(findings[2].Message, "malformed ignore-transitive pattern")
+	assert.Equal(12, findings[2].Line)
+}
+
+func TestLintHooks(t *testing.T) {
+	assert := require.New(t)
+
+	f := writeTemp(t, "vendor.conf", `github.com/rancher/trash
+github.com/foo/a    develop
+-github.com/foo/a/docs
+package=github.com/foo/a/plugin
+`)
+	defer os.RemoveAll(filepath.Dir(f))
+
+	linter := &Linter{
+		Exists:   func(pkg string) bool { return pkg == "github.com/foo/a/plugin" },
+		IsBranch: func(i Import) bool { return i.Version == "develop" },
+	}
+	findings, err := linter.Lint(f)
+	assert.Nil(err)
+	assert.Len(findings, 2)
+	assert.Contains(findings[0].Message, "branch 'develop'")
+	assert.Contains(findings[1].Message, "matches nothing")
+}
+
+func TestLintMappings(t *testing.T) {
+	assert := require.New(t)
+
+	flat := writeTemp(t, "vendor.conf", `github.com/rancher/trash
+k8s.io/kubernetes v1.10.0 map=k8s.io/api:staging/src/k8s.io/api,map=k8s.io/client-go
+`)
+	yml := writeTemp(t, "trash.yml", `package: github.com/rancher/trash
+import:
+- package: k8s.io/kubernetes
+  version: v1.10.0
+  map:
+  - package: k8s.io/api
+    dir: staging/src/k8s.io/api
+  - package: k8s.io/client-go
+`)
+	defer os.RemoveAll(filepath.Dir(flat))
+	defer os.RemoveAll(filepath.Dir(yml))
+
+	findings, err := (&Linter{}).Lint(flat)
+	assert.Nil(err)
+	assert.Len(findings, 1)
+	assert.Equal(2, findings[0].Line)
+	assert.Contains(findings[0].Message, "malformed mapping 'k8s.io/client-go'")
+
+	findings, err = (&Linter{}).Lint(yml)
+	assert.Nil(err)
+	assert.Len(findings, 1)
+	assert.Equal(3, findings[0].Line)
+	assert.Contains(findings[0].Message, "malformed mapping")
+}
@@ -0,0 +1,195 @@
+package conf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TOMLTable is a table of a TOML file, like the [[projects]] of a Gopkg.lock: Name is the table's name, without brackets.
+// It's what dep's files need, not the whole of TOML: strings, arrays of them (on one line or several),
+// and other values (numbers, booleans) as written. The rest, like inline tables, is an error rather than misread.
+type TOMLTable struct {
+	Name   string
+	Values map[string]string
+	Lists  map[string][]string
+}
+
+var errUnterminatedArray = errors.New("unterminated array")
+
+// ParseTOML reads the tables of TOML data in order. The keys before the first table are in a table with an empty name.
+func ParseTOML(data []byte) ([]TOMLTable, error) {
+	tables := []TOMLTable{{Values: map[string]string{}, Lists: map[string][]string{}}}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			brackets := 1
+			if strings.HasPrefix(line, "[[") {
+				brackets = 2
+			}
+			name := ""
+			if len(line) > 2*brackets && strings.HasSuffix(line, strings.Repeat("]", brackets)) {
+				name = strings.TrimSpace(line[brackets : len(line)-brackets])
+			}
+			if name == "" || strings.ContainsAny(name, "[]") {
+				return nil, fmt.Errorf("line %d: malformed table header '%s'", n, line)
+			}
+			tables = append(tables, TOMLTable{Name: name, Values: map[string]string{}, Lists: map[string][]string{}})
+			continue
+		}
+		key, value, err := splitTOMLKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		t := &tables[len(tables)-1]
+		if !strings.HasPrefix(value, "[") {
+			v, rest, err := scanTOMLValue(value)
+			if err == nil && rest != "" {
+				err = fmt.Errorf("unexpected '%s' after the value", rest)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: '%s': %s", n, key, err)
+			}
+			t.Values[key] = v
+			continue
+		}
+		// arrays can go on over several lines
+		list, err := scanTOMLArray(value)
+		for err == errUnterminatedArray && scanner.Scan() {
+			n++
+			value += " " + strings.TrimSpace(stripTOMLComment(scanner.Text()))
+			list, err = scanTOMLArray(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: '%s': %s", n, key, err)
+		}
+		t.Lists[key] = list
+	}
+	return tables, scanner.Err()
+}
+
+// splitTOMLKey splits a key = value line. Keys are bare or quoted: dotted keys are not supported.
+func splitTOMLKey(line string) (string, string, error) {
+	key, rest := "", ""
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		var err error
+		if key, rest, err = scanTOMLValue(line); err != nil {
+			return "", "", fmt.Errorf("invalid key: %s", err)
+		}
+	} else if eq := strings.Index(line, "="); eq >= 0 {
+		key, rest = strings.TrimSpace(line[:eq]), line[eq:]
+		for _, c := range key {
+			if c == '.' {
+				return "", "", fmt.Errorf("dotted key '%s' is not supported", key)
+			}
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+				return "", "", fmt.Errorf("invalid key '%s'", key)
+			}
+		}
+	}
+	if key == "" || !strings.HasPrefix(rest, "=") {
+		return "", "", fmt.Errorf("expected key = value, got '%s'", line)
+	}
+	return key, strings.TrimSpace(rest[1:]), nil
+}
+
+// scanTOMLValue reads the string or other value at the start of s, up to a comma or closing bracket, and returns what follows it
+func scanTOMLValue(s string) (string, string, error) {
+	switch {
+	case s == "":
+		return "", "", errors.New("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return "", "", errors.New("multi-line strings are not supported")
+	case strings.HasPrefix(s, "{"):
+		return "", "", errors.New("inline tables are not supported")
+	case strings.HasPrefix(s, "["):
+		return "", "", errors.New("nested arrays are not supported")
+	case strings.HasPrefix(s, "'"):
+		if end := strings.Index(s[1:], "'"); end >= 0 {
+			return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+		}
+		return "", "", fmt.Errorf("unterminated string %s", s)
+	case strings.HasPrefix(s, `"`):
+		for k := 1; k < len(s); k++ {
+			switch s[k] {
+			case '\\':
+				k++
+			case '"':
+				v, err := strconv.Unquote(s[:k+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", s[:k+1])
+				}
+				return v, strings.TrimSpace(s[k+1:]), nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string %s", s)
+	}
+	end := strings.IndexAny(s, ",]")
+	if end < 0 {
+		end = len(s)
+	}
+	v := strings.TrimSpace(s[:end])
+	if v == "" {
+		return "", "", errors.New("missing value")
+	}
+	if strings.ContainsAny(v, " \t\"'{}=") {
+		return "", "", fmt.Errorf("unsupported value '%s'", v)
+	}
+	return v, s[end:], nil
+}
+
+// scanTOMLArray reads an array of values. It returns errUnterminatedArray if it has no closing bracket yet.
+func scanTOMLArray(s string) ([]string, error) {
+	list := []string{}
+	rest := strings.TrimSpace(strings.TrimPrefix(s, "["))
+	for {
+		if rest == "" {
+			return nil, errUnterminatedArray
+		}
+		if rest[0] == ']' {
+			if rest = strings.TrimSpace(rest[1:]); rest != "" {
+				return nil, fmt.Errorf("unexpected '%s' after the array", rest)
+			}
+			return list, nil
+		}
+		item, r, err := scanTOMLValue(rest)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		switch rest = strings.TrimSpace(r); {
+		case strings.HasPrefix(rest, ","):
+			rest = strings.TrimSpace(rest[1:])
+		case rest != "" && rest[0] != ']':
+			return nil, fmt.Errorf("expected ',' or ']' before '%s'", rest)
+		}
+	}
+}
+
+// stripTOMLComment cuts the comment off the line, minding # in strings
+func stripTOMLComment(line string) string {
+	var quote rune
+	escaped := false
+	for k, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:k]
+		}
+	}
+	return line
+}
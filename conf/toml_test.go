@@ -0,0 +1,73 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTOML(t *testing.T) {
+	assert := require.New(t)
+
+	tables, err := ParseTOML([]byte(`required = ["github.com/foo/tool"] # the tools
+ignored = [
+  "github.com/foo/x", # not this one
+  'github.com/foo/y',
+]
+
+[[constraint]]
+  name = "github.com/foo/a"
+  version = "^1.2.0"
+
+[[override]]
+  name = "github.com/foo/b"
+  source = "https://github.com/fork/b.git#fork"
+
+[prune]
+  go-tests = true
+`))
+	assert.Nil(err)
+	assert.Len(tables, 4)
+	assert.Equal("", tables[0].Name)
+	assert.Equal([]string{"github.com/foo/tool"}, tables[0].Lists["required"])
+	assert.Equal([]string{"github.com/foo/x", "github.com/foo/y"}, tables[0].Lists["ignored"])
+	assert.Equal("constraint", tables[1].Name)
+	assert.Equal("^1.2.0", tables[1].Values["version"])
+	assert.Equal("https://github.com/fork/b.git#fork", tables[2].Values["source"])
+	assert.Equal("true", tables[3].Values["go-tests"])
+
+	tables, err = ParseTOML([]byte(`"quoted key" = "a, b # c \"d\""
+ignored = ["a,b", 'c]d', "e\"f",
+  "g" ,
+  ]
+digest = 1
+[[projects]]
+  pruneopts = "UT"
+`))
+	assert.Nil(err)
+	assert.Equal("a, b # c \"d\"", tables[0].Values["quoted key"])
+	assert.Equal([]string{"a,b", "c]d", "e\"f", "g"}, tables[0].Lists["ignored"])
+	assert.Equal("1", tables[0].Values["digest"])
+	assert.Equal("UT", tables[1].Values["pruneopts"])
+
+	for data, message := range map[string]string{
+		`metadata = { a = "b" }`:    "line 1: 'metadata': inline tables are not supported",
+		`list = [{ name = "a" }]`:   "line 1: 'list': inline tables are not supported",
+		`list = [["a"], ["b"]]`:     "line 1: 'list': nested arrays are not supported",
+		"s = \"\"\"\nmulti\n\"\"\"": "line 1: 's': multi-line strings are not supported",
+		`prune.go-tests = true`:     "line 1: dotted key 'prune.go-tests' is not supported",
+		`name = "a" "b"`:            "line 1: 'name': unexpected '\"b\"' after the value",
+		`name = "a`:                 "line 1: 'name': unterminated string \"a",
+		`name = `:                   "line 1: 'name': missing value",
+		`name = a b`:                "line 1: 'name': unsupported value 'a b'",
+		`list = ["a" "b"]`:          "line 1: 'list': expected ',' or ']' before '\"b\"]'",
+		`list = ["a"] x`:            "line 1: 'list': unexpected 'x' after the array",
+		"list = [\n  \"a\",\n":      "line 2: 'list': unterminated array",
+		"[[projects]\n":             "line 1: malformed table header '[[projects]'",
+		`just a line`:               "line 1: expected key = value, got 'just a line'",
+	} {
+		_, err := ParseTOML([]byte(data))
+		assert.NotNil(err, data)
+		assert.Equal(message, err.Error(), data)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/semver"
+)
+
+// Policies for the packages that transitive imports require with different versions
+const (
+	rootWins      = "root-wins"      // the version in the conf file, or else the first requester's
+	highestSemver = "highest-semver" // the highest semantic version, the conf file's included
+	failConflicts = "fail"           // conflicts are errors, unless the conf file pins the package
+)
+
+var conflictPolicies = []string{rootWins, highestSemver, failConflicts}
+
+// conflictPolicy is how conflicting transitive imports are resolved
+var conflictPolicy = rootWins
+
+func validPolicy(policy string) bool {
+	for _, p := range conflictPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveConflicts picks one import for each package the transitive imports require, following the policy, and tells about
+// the conflicts. It returns the imports to add to the conf imports: the ones that win over a conf import replace its version.
+// The winners of conflicts record why they won.
+func resolveConflicts(policy string, trashConf *conf.Conf, extraImports []conf.Import) ([]conf.Import, error) {
+	requests := map[string][]conf.Import{}
+	for _, i := range extraImports {
+		if !hasRequest(requests[i.Package], i) {
+			requests[i.Package] = append(requests[i.Package], i)
+		}
+	}
+	pkgs := []string{}
+	for pkg := range requests {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	added := []conf.Import{}
+	failed := []string{}
+	for _, pkg := range pkgs {
+		candidates := requests[pkg]
+		root, inRoot := trashConf.Get(pkg)
+		if inRoot {
+			candidates = append([]conf.Import{root}, candidates...)
+		}
+		if !conflicting(candidates) {
+			if !inRoot {
+				added = append(added, candidates[0])
+			}
+			continue
+		}
+
+		wanted := []string{}
+		for _, c := range candidates {
+			wanted = append(wanted, fmt.Sprintf("%s wants %s", requester(trashConf, c), versionAndRepo(c)))
+		}
+		var chosen conf.Import
+		why := ""
+		switch {
+		case policy == highestSemver:
+			chosen, why = highest(candidates)
+		case inRoot:
+			chosen, why = root, "pinned in "+trashConf.ConfFile()
+		case policy == failConflicts:
+			failed = append(failed, fmt.Sprintf("'%s': %s", pkg, strings.Join(wanted, ", ")))
+			continue
+		default:
+			chosen, why = candidates[0], "first requester"
+		}
+		chosen.Reason = fmt.Sprintf("%s, %s: %s", policy, why, strings.Join(wanted, ", "))
+		logrus.Warnf("Conflicting versions of '%s': %s. Using %s from %s (%s)", pkg, strings.Join(wanted, ", "), versionAndRepo(chosen), requester(trashConf, chosen), why)
+
+		if !inRoot {
+			added = append(added, chosen)
+			continue
+		}
+		for k := range trashConf.Imports {
+			if i := &trashConf.Imports[k]; i.Package == pkg {
+				// what the first pass resolved the root version to goes with it: the chosen version gets resolved on its own
+				if i.Version != chosen.Version || i.Repo != chosen.Repo {
+					i.Commit, i.Ref = chosen.Commit, chosen.Ref
+				}
+				i.Version, i.Repo, i.From, i.Reason = chosen.Version, chosen.Repo, chosen.From, chosen.Reason
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("conflicting versions of transitive imports (pin them in %s, or use another --conflicts policy):\n  %s", trashConf.ConfFile(), strings.Join(failed, "\n  "))
+	}
+	trashConf.Dedupe()
+	return added, nil
+}
+
+func hasRequest(requests []conf.Import, i conf.Import) bool {
+	for _, r := range requests {
+		if r.Version == i.Version && r.Repo == i.Repo && r.From == i.From {
+			return true
+		}
+	}
+	return false
+}
+
+func conflicting(candidates []conf.Import) bool {
+	for _, c := range candidates[1:] {
+		if c.Version != candidates[0].Version || c.Repo != candidates[0].Repo {
+			return true
+		}
+	}
+	return false
+}
+
+// highest picks the highest semantic version: the other versions lose, and the first candidate wins ties
+func highest(candidates []conf.Import) (conf.Import, string) {
+	var best *semver.Version
+	chosen := -1
+	for k, c := range candidates {
+		v, err := semver.Parse(c.Version)
+		if err != nil {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, chosen = v, k
+		}
+	}
+	if chosen < 0 {
+		return candidates[0], "no semantic versions, first requester"
+	}
+	return candidates[chosen], "highest semantic version"
+}
+
+func requester(trashConf *conf.Conf, i conf.Import) string {
+	if i.From == "" {
+		return trashConf.ConfFile()
+	}
+	return i.From
+}
+
+func versionAndRepo(i conf.Import) string {
+	if i.Repo == "" {
+		return i.Version
+	}
+	return fmt.Sprintf("%s (%s)", i.Version, i.Repo)
+}
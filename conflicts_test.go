@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func conflictingImports() (*conf.Conf, []conf.Import) {
+	trashConf := &conf.Conf{Imports: []conf.Import{
+		{Package: "example.com/a", Version: "v1.0.0", Options: conf.Options{Transitive: true}},
+		{Package: "example.com/b", Version: "v1.0.0", Options: conf.Options{Transitive: true}},
+		{Package: "example.com/pinned", Version: "v1.1.0"},
+	}}
+	trashConf.Dedupe()
+	return trashConf, []conf.Import{
+		{Package: "example.com/c", Version: "v1.2.0", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/c", Version: "v1.10.0", From: "example.com/b/vendor.conf"},
+		{Package: "example.com/c", Version: "v1.2.0", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/d", Version: "v2", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/d", Version: "v2", From: "example.com/b/vendor.conf"},
+		{Package: "example.com/pinned", Version: "v1.3.0", From: "example.com/b/vendor.conf"},
+	}
+}
+
+func TestResolveConflictsRootWins(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf, extra := conflictingImports()
+	added, err := resolveConflicts(rootWins, trashConf, extra)
+	assert.Nil(err)
+	assert.Len(added, 2)
+	assert.Equal("v1.2.0", added[0].Version)
+	assert.Contains(added[0].Reason, "root-wins, first requester: example.com/a/vendor.conf wants v1.2.0, example.com/b/vendor.conf wants v1.10.0")
+	assert.Equal("", added[1].Reason)
+
+	pinned, _ := trashConf.Get("example.com/pinned")
+	assert.Equal("v1.1.0", pinned.Version)
+	assert.Contains(pinned.Reason, "pinned in")
+}
+
+func TestResolveConflictsHighestSemver(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf, extra := conflictingImports()
+	added, err := resolveConflicts(highestSemver, trashConf, extra)
+	assert.Nil(err)
+	assert.Equal("v1.10.0", added[0].Version)
+	assert.Equal("example.com/b/vendor.conf", added[0].From)
+
+	pinned, _ := trashConf.Get("example.com/pinned")
+	assert.Equal("v1.3.0", pinned.Version)
+	assert.Contains(pinned.Reason, "highest semantic version")
+}
+
+func TestResolveConflictsResolvedRoot(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf, extra := conflictingImports()
+	for k := range trashConf.Imports {
+		trashConf.Imports[k].Ref, trashConf.Imports[k].Commit = "v1.1.0", "1111111111111111111111111111111111111111"
+	}
+	trashConf.Dedupe()
+	_, err := resolveConflicts(highestSemver, trashConf, extra)
+	assert.Nil(err)
+	pinned, _ := trashConf.Get("example.com/pinned")
+	assert.Equal("v1.3.0", pinned.Version)
+	assert.Equal("", pinned.Commit, "the commit of v1.1.0 must not be checked out for v1.3.0")
+	assert.Equal("", pinned.Ref)
+
+	trashConf, extra = conflictingImports()
+	for k := range trashConf.Imports {
+		trashConf.Imports[k].Ref, trashConf.Imports[k].Commit = "v1.1.0", "1111111111111111111111111111111111111111"
+	}
+	trashConf.Dedupe()
+	_, err = resolveConflicts(rootWins, trashConf, extra)
+	assert.Nil(err)
+	pinned, _ = trashConf.Get("example.com/pinned")
+	assert.Equal("v1.1.0", pinned.Version)
+	assert.Equal("1111111111111111111111111111111111111111", pinned.Commit, "the root version keeps what it resolved to")
+}
+
+func TestResolveConflictsFail(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf, extra := conflictingImports()
+	_, err := resolveConflicts(failConflicts, trashConf, extra)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "'example.com/c': example.com/a/vendor.conf wants v1.2.0, example.com/b/vendor.conf wants v1.10.0")
+	assert.NotContains(err.Error(), "example.com/pinned")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/util"
+)
+
+type versionChange struct {
+	Package string
+	From    string
+	To      string
+}
+
+type deletion struct {
+	Path   string
+	Reason string
+}
+
+// plan is what a run would do to the vendor dir
+type plan struct {
+	Versions  []versionChange
+	Added     []string // packages the vendor dir would get
+	Removed   []string // packages the vendor dir would lose
+	Deletions []deletion
+
+	// scratchDir is the absolute path of the dir the dry run populates instead of targetDir
+	scratchDir, targetDir string
+}
+
+// recorder is the plan of the dry run in progress, if any: the cleanup records what it deletes in it
+var recorder *plan
+
+// recordDeletion records what the cleanup deletes in the scratch dir of a dry run, at its path in the target dir
+func recordDeletion(p, reason string) {
+	if recorder == nil {
+		return
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(recorder.scratchDir, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	recorder.Deletions = append(recorder.Deletions, deletion{path.Join(recorder.targetDir, filepath.ToSlash(rel)), reason})
+}
+
+// dryRun does the run in a scratch copy of the target dir and prints what changed, leaving the vendor dir, trash.lock and the conf alone
+func dryRun(keep, update, includeVendor, insecure bool, trashDir, dir, targetDir string, trashConf *conf.Conf) error {
+	p, err := planRun(keep, update, includeVendor, insecure, trashDir, dir, targetDir, trashConf)
+	if err != nil {
+		return err
+	}
+	p.print(targetDir)
+	return nil
+}
+
+// planRun populates a scratch dir next to the target dir the way trash populates the target dir, recording what the cleanup
+// deletes, and compares it with the target dir. In update mode, the scratch dir starts as a copy of the target dir.
+func planRun(keep, update, includeVendor, insecure bool, trashDir, dir, targetDir string, trashConf *conf.Conf) (*plan, error) {
+	defer os.Chdir(dir)
+
+	locked := map[string]conf.Import{}
+	if lock, err := conf.Parse(filepath.Join(dir, "trash.lock")); err == nil {
+		locked = lock.ImportMap
+	}
+
+	// dot dirs are not looked at for the project's imports
+	scratchDir := path.Join(path.Dir(targetDir), "."+path.Base(targetDir)+".dry-run")
+	p := &plan{scratchDir: path.Join(dir, scratchDir), targetDir: targetDir}
+	if err := os.RemoveAll(p.scratchDir); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(p.scratchDir)
+	if _, err := os.Stat(path.Join(dir, targetDir)); update && err == nil {
+		if bytes, err := exec.Command("cp", "-a", path.Join(dir, targetDir), p.scratchDir).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("`cp -a %s %s` failed:\n%s", path.Join(dir, targetDir), p.scratchDir, bytes)
+		}
+	}
+
+	recorder, skipDirs = p, []string{targetDir}
+	defer func() { recorder, skipDirs = nil, nil }()
+	if err := populate(keep, update, includeVendor, insecure, trashDir, dir, scratchDir, trashConf); err != nil {
+		return nil, err
+	}
+
+	for _, i := range trashConf.Imports {
+		if update && !i.Update {
+			continue
+		}
+		if l, ok := locked[i.Package]; !ok {
+			p.Versions = append(p.Versions, versionChange{i.Package, "", displayVersion(i)})
+		} else if l.Version != i.Version || l.Repo != i.Repo || l.Commit != i.Commit {
+			p.Versions = append(p.Versions, versionChange{i.Package, displayVersion(l), displayVersion(i)})
+		}
+	}
+	if !update {
+		for pkg, l := range locked {
+			if _, ok := coveringImport(trashConf, pkg); !ok {
+				p.Versions = append(p.Versions, versionChange{pkg, displayVersion(l), ""})
+			}
+		}
+	}
+	sort.Sort(byVersionChange(p.Versions))
+
+	oldPackages, err := vendoredPackages(path.Join(dir, targetDir))
+	if err != nil {
+		return nil, err
+	}
+	newPackages, err := vendoredPackages(p.scratchDir)
+	if err != nil {
+		return nil, err
+	}
+	for pkg := range newPackages {
+		if !oldPackages[pkg] {
+			p.Added = append(p.Added, pkg)
+		}
+	}
+	for pkg := range oldPackages {
+		if !newPackages[pkg] {
+			p.Removed = append(p.Removed, pkg)
+		}
+	}
+	sort.Strings(p.Added)
+	sort.Strings(p.Removed)
+	return p, nil
+}
+
+// vendoredPackages lists the packages (dirs with Go files) in the vendor dir
+func vendoredPackages(vendorDir string) (util.Packages, error) {
+	packages := util.Packages{}
+	err := filepath.Walk(vendorDir, func(filePath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(filePath, ".go") {
+			return nil
+		}
+		packages[filepath.ToSlash(filepath.Dir(filePath)[len(vendorDir+"/"):])] = true
+		return nil
+	})
+	return packages, err
+}
+
+func (p *plan) print(targetDir string) {
+	fmt.Println("Versions:")
+	if len(p.Versions) == 0 {
+		fmt.Println("  no changes")
+	}
+	for _, v := range p.Versions {
+		switch {
+		case v.From == "":
+			fmt.Printf("  + %s %s\n", v.Package, v.To)
+		case v.To == "":
+			fmt.Printf("  - %s %s\n", v.Package, v.From)
+		default:
+			fmt.Printf("  ~ %s %s -> %s\n", v.Package, v.From, v.To)
+		}
+	}
+	fmt.Printf("Packages in %s:\n", targetDir)
+	if len(p.Added)+len(p.Removed) == 0 {
+		fmt.Println("  no changes")
+	}
+	for _, pkg := range p.Added {
+		fmt.Printf("  + %s\n", pkg)
+	}
+	for _, pkg := range p.Removed {
+		fmt.Printf("  - %s\n", pkg)
+	}
+	fmt.Println("Deleted by the cleanup after copying:")
+	if len(p.Deletions) == 0 {
+		fmt.Println("  nothing")
+	}
+	for _, d := range p.Deletions {
+		fmt.Printf("  %s (%s)\n", d.Path, d.Reason)
+	}
+	logrus.Info("Dry run: nothing was changed")
+}
+
+type byVersionChange []versionChange
+
+func (v byVersionChange) Len() int           { return len(v) }
+func (v byVersionChange) Less(i, j int) bool { return v[i].Package < v[j].Package }
+func (v byVersionChange) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestPlanRun(t *testing.T) {
+	assert := require.New(t)
+
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+
+	root := writeTree(t, map[string]string{
+		"up/a.go":                                "package a\n",
+		"up/lib/lib.go":                          "package lib\n",
+		"up/lib/lib_test.go":                     "package lib\n",
+		"up/lib/testdata/x.txt":                  "data\n",
+		"up/unused/u.go":                         "package unused\n",
+		"up/docs/x/README.md":                    "docs\n",
+		"up/staging/src/example.com/mapped/m.go": "package mapped\n",
+		"up/vendor/example.com/nested/n.go":      "package nested\n",
+		"proj/main.go":                           "package main\n\nimport (\n\t_ \"example.com/a/lib\"\n\t_ \"example.com/mapped\"\n)\n",
+		"proj/vendor/example.com/old/o.go":       "package old\n",
+		"cache/src/example.com/.keep":            "",
+		"proj/vendor.conf":                       "example.com/proj\nexample.com/a v1.0.0 map=example.com/mapped:staging/src/example.com/mapped\n-example.com/a/lib/testdata\n",
+	})
+	defer os.RemoveAll(root)
+	git := func(dir string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...)
+		cmd.Dir = dir
+		bytes, err := cmd.CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+	}
+	up, trashDir, dir := filepath.Join(root, "up"), filepath.Join(root, "cache"), filepath.Join(root, "proj")
+	git(up, "init", "-q")
+	git(up, "add", "-A")
+	git(up, "commit", "-q", "-m", "a")
+	git(up, "tag", "v1.0.0")
+	git(root, "clone", "-q", up, filepath.Join(trashDir, "src", "example.com", "a"))
+
+	trashConf, err := conf.Parse(filepath.Join(dir, "vendor.conf"))
+	assert.Nil(err)
+	p, err := planRun(false, false, false, false, trashDir, dir, "vendor", trashConf)
+	assert.Nil(err)
+
+	assert.Len(p.Versions, 1)
+	assert.Equal("example.com/a", p.Versions[0].Package)
+	assert.Equal("", p.Versions[0].From, "not in trash.lock")
+	assert.Equal([]string{"example.com/a/lib", "example.com/mapped"}, p.Added)
+	assert.Equal([]string{"example.com/old"}, p.Removed)
+	deletions := map[string]string{}
+	for _, d := range p.Deletions {
+		deletions[d.Path] = d.Reason
+	}
+	assert.Equal(map[string]string{
+		"vendor/example.com/a/.git":            ".git dir",
+		"vendor/example.com/a/a.go":            "package not imported",
+		"vendor/example.com/a/docs":            "not imported",
+		"vendor/example.com/a/lib/lib_test.go": "test file",
+		"vendor/example.com/a/lib/testdata":    "excluded",
+		"vendor/example.com/a/staging":         "not imported",
+		"vendor/example.com/a/unused":          "not imported",
+		"vendor/example.com/a/vendor":          "not imported",
+	}, deletions)
+
+	for _, f := range []string{"proj/vendor/example.com/old/o.go", "cache/src/example.com/a/a.go"} {
+		_, err := os.Stat(filepath.Join(root, f))
+		assert.Nil(err, "%s is left alone", f)
+	}
+	for _, f := range []string{"proj/trash.lock", "proj/.vendor.dry-run"} {
+		_, err := os.Stat(filepath.Join(root, f))
+		assert.True(os.IsNotExist(err), f)
+	}
+
+	defer func(f bool) { flatten = f }(flatten)
+	flatten = true
+	trashConf, err = conf.Parse(filepath.Join(dir, "vendor.conf"))
+	assert.Nil(err)
+	p, err = planRun(false, false, false, false, trashDir, dir, "vendor", trashConf)
+	assert.Nil(err)
+	deletions = map[string]string{}
+	for _, d := range p.Deletions {
+		deletions[d.Path] = d.Reason
+	}
+	assert.Equal("nested vendor dir, flattened", deletions["vendor/example.com/a/vendor"])
+	assert.Equal("not imported", deletions["vendor/example.com/nested"], "lifted, but the project doesn't import it")
+	flatten = false
+
+	trashConf, err = conf.Parse(filepath.Join(dir, "vendor.conf"))
+	assert.Nil(err)
+	trashConf.Imports[0].Update = true
+	p, err = planRun(false, true, false, false, trashDir, dir, "vendor", trashConf)
+	assert.Nil(err)
+	assert.Equal([]string{"example.com/a/lib", "example.com/mapped"}, p.Added)
+	assert.Len(p.Removed, 0, "update mode leaves the other packages alone")
+	_, err = os.Stat(filepath.Join(trashDir, "src", "example.com", "a", "a.go"))
+	assert.Nil(err, "dry runs don't move the cache to the vendor dir")
+	assert.True(strings.HasSuffix(p.scratchDir, ".vendor.dry-run"))
+}
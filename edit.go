@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+)
+
+var addCommand = cli.Command{
+	Name:      "add",
+	Usage:     "Add a package to the conf file and vendor it",
+	ArgsUsage: "<package>[@version]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "repo",
+			Usage: "Git URL to fetch the package from",
+		},
+		cli.BoolFlag{
+			Name:  "transitive",
+			Usage: "Also vendor the package's own dependencies",
+		},
+	},
+	Action: add,
+}
+
+var removeCommand = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm"},
+	Usage:     "Remove a package from the conf file and the vendor dir",
+	ArgsUsage: "<package>",
+	Action:    remove,
+}
+
+var setCommand = cli.Command{
+	Name:      "set",
+	Usage:     "Set the version of a package in the conf file and vendor it",
+	ArgsUsage: "<package> <version>",
+	Action:    set,
+}
+
+func add(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelp(c, "add")
+		return fmt.Errorf("expected 1 argument, got %d", c.NArg())
+	}
+	dir, trashDir, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	insecure := c.GlobalBool("insecure")
+
+	i := conf.Import{
+		Package: c.Args().First(),
+		Repo:    c.String("repo"),
+		Options: conf.Options{Transitive: c.Bool("transitive")},
+	}
+	if at := strings.Index(i.Package, "@"); at >= 0 {
+		i.Package, i.Version = i.Package[:at], i.Package[at+1:]
+	}
+	if _, ok := trashConf.Get(i.Package); ok {
+		err := fmt.Errorf("package '%s' is already imported: use `trash set` to change its version", i.Package)
+		logrus.Error(err)
+		return err
+	}
+
+	os.MkdirAll(trashDir, 0755)
+	os.Setenv("GOPATH", trashDir)
+	if i.Version == "" {
+		if i.Version, err = latestVersion(trashDir, i, insecure); err != nil {
+			logrus.Error(err)
+			return err
+		}
+		logrus.Infof("Using the latest version of '%s': '%s'", i.Package, i.Version)
+	} else {
+		// fail before touching the conf file if the version can't be checked out
+		prepareCache(trashDir, i, insecure)
+		checkout(trashDir, i)
+	}
+	os.Chdir(dir)
+
+	if err := trashConf.Add(i); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	logrus.Infof("Added '%s' '%s' to '%s'", i.Package, i.Version, trashConf.ConfFile())
+	return updateOne(c, dir, trashDir, trashConf, i.Package)
+}
+
+func remove(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelp(c, "remove")
+		return fmt.Errorf("expected 1 argument, got %d", c.NArg())
+	}
+	dir, _, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	pkg := c.Args().First()
+
+	if err := trashConf.Remove(pkg); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	logrus.Infof("Removed '%s' from '%s'", pkg, trashConf.ConfFile())
+
+	targetDir := c.GlobalString("target")
+	logrus.Infof("Removing '%s'", path.Join(targetDir, pkg))
+	if err := os.RemoveAll(path.Join(dir, targetDir, pkg)); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	// whatever only the removed package needed goes away with a full cleanup
+	if err := cleanup(false, dir, targetDir, trashConf); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	return nil
+}
+
+func set(c *cli.Context) error {
+	if c.NArg() != 2 {
+		cli.ShowCommandHelp(c, "set")
+		return fmt.Errorf("expected 2 arguments, got %d", c.NArg())
+	}
+	dir, trashDir, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	pkg, version := c.Args().Get(0), c.Args().Get(1)
+
+	i, ok := trashConf.Get(pkg)
+	if !ok {
+		err := fmt.Errorf("package '%s' is not imported: use `trash add` to add it", pkg)
+		logrus.Error(err)
+		return err
+	}
+	i.Version = version
+
+	// fail before touching the conf file if the version can't be checked out
+	os.MkdirAll(trashDir, 0755)
+	os.Setenv("GOPATH", trashDir)
+	prepareCache(trashDir, i, c.GlobalBool("insecure"))
+	checkout(trashDir, i)
+	os.Chdir(dir)
+
+	if err := trashConf.SetVersion(pkg, version); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	logrus.Infof("Set '%s' to '%s' in '%s'", pkg, version, trashConf.ConfFile())
+	return updateOne(c, dir, trashDir, trashConf, pkg)
+}
+
+// updateOne vendors a single package (and its transitive dependencies) the way `trash -u` does
+func updateOne(c *cli.Context, dir, trashDir string, trashConf *conf.Conf, pkg string) error {
+	for k := range trashConf.Imports {
+		if trashConf.Imports[k].Package == pkg {
+			trashConf.Imports[k].Update = true
+		}
+	}
+	err := trash(c.GlobalBool("keep"), true, c.GlobalBool("include-vendor"), c.GlobalBool("insecure"), trashDir, dir, c.GlobalString("target"), trashConf)
+	if err != nil {
+		logrus.Error(err)
+	}
+	return err
+}
+
+// latestVersion checks out the latest commit of the package and returns its tag, if it has one, or its SHA
+func latestVersion(trashDir string, i conf.Import, insecure bool) (string, error) {
+	i.Version = "HEAD"
+	prepareCache(trashDir, i, insecure)
+	checkout(trashDir, i)
+	return getLatestVersion(path.Join(trashDir, "src"), i.Package)
+}
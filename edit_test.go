@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestEditResolvedVersions(t *testing.T) {
+	assert := require.New(t)
+
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	root, err := ioutil.TempDir("", "trash-edit")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	git := func(dir, date string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		bytes, err := cmd.CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+		return strings.TrimSpace(string(bytes))
+	}
+	commit := func(dir, date, content string) {
+		assert.Nil(ioutil.WriteFile(filepath.Join(dir, "up.go"), []byte("package up // "+content+"\n"), 0644))
+		git(dir, date, "add", "-A")
+		git(dir, date, "commit", "-q", "-m", content)
+	}
+	up := filepath.Join(root, "up")
+	assert.Nil(os.MkdirAll(up, 0755))
+	git(up, "", "init", "-q")
+	git(up, "", "checkout", "-q", "-b", "master")
+	commit(up, "2019-01-01T12:00:00Z", "one")
+	git(up, "", "tag", "v1.0.0")
+	commit(up, "2019-06-01T12:00:00Z", "two")
+	git(up, "", "tag", "v1.5.0")
+	git(up, "", "checkout", "-q", "-b", "develop")
+	commit(up, "2019-09-01T12:00:00Z", "three")
+	git(up, "", "checkout", "-q", "master")
+
+	trashDir := filepath.Join(root, "cache")
+	dir := filepath.Join(root, "proj")
+	assert.Nil(os.MkdirAll(dir, 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "vendor.conf"), []byte("example.com/proj\nexample.com/foo v1.0.0\n"), 0644))
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport (\n\t_ \"example.com/bar\"\n\t_ \"example.com/foo\"\n)\n"), 0644))
+
+	run := func(args ...string) error {
+		// update mode moves the cached repos to the vendor dir: clone them again rather than have trash go get them
+		for _, pkg := range []string{"example.com/foo", "example.com/bar"} {
+			if _, err := os.Stat(filepath.Join(trashDir, "src", pkg)); os.IsNotExist(err) {
+				assert.Nil(os.MkdirAll(filepath.Join(trashDir, "src", filepath.Dir(pkg)), 0755))
+				git(root, "", "clone", "-q", up, filepath.Join(trashDir, "src", pkg))
+			}
+		}
+		return newApp().Run(append([]string{"trash", "--cache", trashDir, "-C", dir}, args...))
+	}
+	vendored := func(pkg string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "vendor", pkg, "up.go"))
+		assert.Nil(err)
+		return string(data)
+	}
+	locked := func(pkg string) conf.Import {
+		lock, err := conf.Parse(filepath.Join(dir, "trash.lock"))
+		assert.Nil(err)
+		i, ok := lock.Get(pkg)
+		assert.True(ok, pkg)
+		return i
+	}
+
+	assert.Nil(run("add", "example.com/bar@^1.0.0"))
+	assert.Equal("package up // two\n", vendored("example.com/bar"))
+	assert.Equal("v1.5.0", locked("example.com/bar").Ref)
+
+	for version, content := range map[string]string{
+		"^1.0.0":             "two",
+		"@2019-03-01":        "one",
+		"master@2019-07-01":  "two",
+		"refs/heads/develop": "three",
+	} {
+		assert.Nil(run("set", "example.com/foo", version), version)
+		assert.Equal("package up // "+content+"\n", vendored("example.com/foo"), version)
+		i := locked("example.com/foo")
+		assert.Equal(version, i.Version)
+		assert.NotEqual("", i.Commit, version)
+
+		trashConf, err := conf.Parse(filepath.Join(dir, "vendor.conf"))
+		assert.Nil(err)
+		i, _ = trashConf.Get("example.com/foo")
+		assert.Equal(version, i.Version)
+
+		assert.Nil(run("tidy", "--dry-run"), version)
+	}
+}
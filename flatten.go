@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/rancher/trash/conf"
+)
+
+// flatten lifts the packages of the dependencies' vendor dirs into the top-level vendor dir
+var flatten bool
+
+// nestedPackage is a package in a dependency's vendor dir
+type nestedPackage struct {
+	pkg string
+	// vendor is the vendor dir it's in, relative to the top-level vendor dir, and dir the package's dir
+	vendor, dir string
+}
+
+// flattenVendor lifts the packages of the nested vendor dirs into the top-level vendor dir, unless a conf import covers them
+// or the top-level vendor dir has them already,
+// and removes the nested vendor dirs. The first vendor dir (in path order) a package is in wins: the others having it
+// with other content, and the conf import's copy if it's different, are conflicts. It returns what it did, for trash.lock.
+func flattenVendor(vendorDir string, trashConf *conf.Conf) ([]conf.Flattened, error) {
+	nested, vendors, err := nestedPackages(vendorDir)
+	if err != nil {
+		return nil, err
+	}
+	flattened := []conf.Flattened{}
+	index := map[string]int{}
+	for _, n := range nested {
+		digest, err := packageDigest(n.dir)
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(vendorDir, n.pkg)
+		topDigest, err := packageDigest(target)
+		if err != nil {
+			return nil, err
+		}
+		k, seen := index[n.pkg]
+		if !seen {
+			index[n.pkg] = len(flattened)
+			k = len(flattened)
+			if _, covered := coveringImport(trashConf, n.pkg); !covered && topDigest == "" {
+				logrus.Infof("Lifting '%s' from '%s'", n.pkg, n.vendor)
+				if err := liftPackage(n.dir, target); err != nil {
+					return nil, err
+				}
+				flattened = append(flattened, conf.Flattened{Package: n.pkg, From: n.vendor})
+				continue
+			}
+			flattened = append(flattened, conf.Flattened{Package: n.pkg})
+		}
+		if digest != topDigest {
+			logrus.Warnf("'%s' in '%s' is not the same as the one in the top-level vendor dir: dropping it", n.pkg, n.vendor)
+			flattened[k].Conflicts = append(flattened[k].Conflicts, n.vendor)
+		}
+	}
+	for _, v := range vendors {
+		logrus.Debugf("Removing nested vendor dir '%s'", v)
+		recordDeletion(filepath.Join(vendorDir, v), "nested vendor dir, flattened")
+		if err := os.RemoveAll(filepath.Join(vendorDir, v)); err != nil {
+			return nil, err
+		}
+	}
+	result := []conf.Flattened{}
+	for _, f := range flattened {
+		if f.From != "" || len(f.Conflicts) > 0 {
+			result = append(result, f)
+		}
+	}
+	sort.Sort(byFlattenedPackage(result))
+	return result, nil
+}
+
+// nestedPackages finds the dirs with files in the nested vendor dirs, in path order, and the outermost nested vendor dirs
+func nestedPackages(vendorDir string) ([]nestedPackage, []string, error) {
+	nested := []nestedPackage{}
+	vendors := []string{}
+	err := filepath.Walk(vendorDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || p == vendorDir {
+			return nil
+		}
+		rel, err := filepath.Rel(vendorDir, p)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		v := -1
+		for k := len(parts) - 1; k > 0; k-- {
+			if parts[k] == "vendor" {
+				v = k
+				break
+			}
+		}
+		switch {
+		case v < 0:
+			return nil
+		case v == len(parts)-1:
+			if !strings.Contains("/"+filepath.ToSlash(path.Dir(rel))+"/", "/vendor/") {
+				vendors = append(vendors, rel)
+			}
+			return nil
+		}
+		if hasFiles, err := hasFiles(p); err != nil || !hasFiles {
+			return err
+		}
+		nested = append(nested, nestedPackage{pkg: path.Join(parts[v+1:]...), vendor: path.Join(parts[:v+1]...), dir: p})
+		return nil
+	})
+	return nested, vendors, err
+}
+
+func hasFiles(dir string) (bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// liftPackage moves the files of the package dir (not its subdirs, which are other packages) to the target dir
+func liftPackage(dir, target string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if err := os.Rename(filepath.Join(dir, f.Name()), filepath.Join(target, f.Name())); err != nil {
+			return fmt.Errorf("could not lift '%s': %s", filepath.Join(dir, f.Name()), err)
+		}
+	}
+	return nil
+}
+
+// packageDigest is the digest of the names and contents of the non-test .go files of the package dir ("" if there's none)
+func packageDigest(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	digest := ""
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".go") || strings.HasSuffix(f.Name(), "_test.go") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return "", err
+		}
+		digest = fmt.Sprintf("%x", sha1.Sum([]byte(digest+f.Name()+string(data))))
+	}
+	return digest, nil
+}
+
+// keepFlattened adds the entries of the last trash.lock to the flattened packages, in update mode: the packages were
+// lifted before, and stay as long as they're in the vendor dir
+func keepFlattened(flattened, previous []conf.Flattened, vendorDir string) []conf.Flattened {
+	recorded := map[string]bool{}
+	for _, f := range flattened {
+		recorded[f.Package] = true
+	}
+	for _, f := range previous {
+		if recorded[f.Package] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(vendorDir, f.Package)); err == nil {
+			flattened = append(flattened, f)
+		}
+	}
+	sort.Sort(byFlattenedPackage(flattened))
+	return flattened
+}
+
+type byFlattenedPackage []conf.Flattened
+
+func (f byFlattenedPackage) Len() int           { return len(f) }
+func (f byFlattenedPackage) Less(i, j int) bool { return f[i].Package < f[j].Package }
+func (f byFlattenedPackage) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
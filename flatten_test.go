@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestFlattenVendor(t *testing.T) {
+	assert := require.New(t)
+
+	vendorDir, err := ioutil.TempDir("", "trash-flatten")
+	assert.Nil(err)
+	defer os.RemoveAll(vendorDir)
+	write := func(file, content string) {
+		assert.Nil(os.MkdirAll(filepath.Dir(filepath.Join(vendorDir, file)), 0755))
+		assert.Nil(ioutil.WriteFile(filepath.Join(vendorDir, file), []byte(content), 0644))
+	}
+	write("example.com/a/a.go", "package a")
+	write("example.com/a/vendor/example.com/x/x.go", "package x")
+	write("example.com/a/vendor/example.com/x/sub/sub.go", "package sub")
+	write("example.com/a/vendor/example.com/c/c.go", "package c // old")
+	write("example.com/a/vendor/example.com/y/vendor/example.com/z/z.go", "package z")
+	write("example.com/b/b.go", "package b")
+	write("example.com/b/vendor/example.com/x/x.go", "package x // other")
+	write("example.com/b/vendor/example.com/x/sub/sub.go", "package sub")
+	write("example.com/c/c.go", "package c")
+
+	trashConf := &conf.Conf{Imports: []conf.Import{{Package: "example.com/a"}, {Package: "example.com/b"}, {Package: "example.com/c"}}}
+	trashConf.Dedupe()
+	flattened, err := flattenVendor(vendorDir, trashConf)
+	assert.Nil(err)
+	assert.Equal([]conf.Flattened{
+		{Package: "example.com/c", Conflicts: []string{"example.com/a/vendor"}},
+		{Package: "example.com/x", From: "example.com/a/vendor", Conflicts: []string{"example.com/b/vendor"}},
+		{Package: "example.com/x/sub", From: "example.com/a/vendor"},
+		{Package: "example.com/z", From: "example.com/a/vendor/example.com/y/vendor"},
+	}, flattened)
+
+	for file, content := range map[string]string{
+		"example.com/c/c.go":       "package c",
+		"example.com/x/x.go":       "package x",
+		"example.com/x/sub/sub.go": "package sub",
+		"example.com/z/z.go":       "package z",
+		"example.com/a/a.go":       "package a",
+		"example.com/b/b.go":       "package b",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(vendorDir, file))
+		assert.Nil(err)
+		assert.Equal(content, string(data))
+	}
+	for _, nested := range []string{"example.com/a/vendor", "example.com/b/vendor"} {
+		_, err := os.Stat(filepath.Join(vendorDir, nested))
+		assert.True(os.IsNotExist(err), nested)
+	}
+
+	kept := keepFlattened([]conf.Flattened{{Package: "example.com/x", From: "example.com/b/vendor"}}, flattened, vendorDir)
+	assert.Len(kept, 4)
+	assert.Equal("example.com/b/vendor", kept[1].From, "what was flattened now wins")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/util"
+)
+
+var graphCommand = cli.Command{
+	Name:  "graph",
+	Usage: "Print the dependency graph",
+	Description: `The repo level graph comes from the conf file and trash.lock (run trash first
+   to have the transitive imports in it). The package level graph comes from the vendor dir.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Value: "dot",
+			Usage: "Output format: dot or json",
+		},
+		cli.StringFlag{
+			Name:  "level",
+			Value: "repo",
+			Usage: "Graph level: repo or package",
+		},
+		cli.StringFlag{
+			Name:  "under",
+			Usage: "Only print the subgraph under this dependency",
+		},
+	},
+	Action: graphDeps,
+}
+
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+}
+
+type depGraph struct {
+	Nodes []string    `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+func graphDeps(c *cli.Context) error {
+	dir, _, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	targetDir := c.GlobalString("target")
+	rootPackage := trashConf.Package
+	if rootPackage == "" {
+		rootPackage = guessRootPackage(dir)
+	}
+	os.Chdir(dir)
+
+	var g *depGraph
+	switch c.String("level") {
+	case "repo":
+		lock, err := conf.Parse("trash.lock")
+		if err != nil {
+			logrus.Warnf("Could not read trash.lock, the graph has no transitive imports: %s", err)
+			lock = &conf.Conf{}
+		}
+		g = repoGraph(rootPackage, trashConf, lock)
+	case "package":
+		graph := util.NewGraph()
+		collectImportGraph(rootPackage, targetDir, targetDir, graph)
+		g = packageGraph(graph)
+	default:
+		return fmt.Errorf("unknown graph level: '%s'", c.String("level"))
+	}
+	if under := c.String("under"); under != "" {
+		g = g.under(under)
+	}
+
+	switch c.String("format") {
+	case "dot":
+		g.printDot()
+	case "json":
+		data, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unknown graph format: '%s'", c.String("format"))
+	}
+	return nil
+}
+
+// repoGraph links the project to the conf imports, and the transitive imports to the imports whose config they come from.
+// The conf imports' edges are labeled with the conf file's name, vendor.conf for a conf that wasn't read from a file.
+func repoGraph(rootPackage string, trashConf, lock *conf.Conf) *depGraph {
+	g := &depGraph{Nodes: []string{rootPackage}, Edges: []graphEdge{}}
+	confFile := confFiles[0]
+	if trashConf.ConfFile() != "" {
+		confFile = path.Base(trashConf.ConfFile())
+	}
+	for _, i := range trashConf.Imports {
+		g.Edges = append(g.Edges, graphEdge{rootPackage, i.Package, confFile})
+	}
+	for _, i := range lock.Imports {
+		if _, ok := trashConf.Get(i.Package); ok {
+			continue
+		}
+		if i.From == "" {
+			g.Edges = append(g.Edges, graphEdge{rootPackage, i.Package, "trash.lock"})
+			continue
+		}
+		parent := path.Dir(i.From)
+		if p, ok := coveringImport(lock, parent); ok {
+			parent = p.Package
+		} else if p, ok := coveringImport(trashConf, parent); ok {
+			parent = p.Package
+		}
+		g.Edges = append(g.Edges, graphEdge{parent, i.Package, strings.TrimPrefix(i.From, parent+"/")})
+	}
+	g.collectNodes()
+	return g
+}
+
+func packageGraph(graph *util.Graph) *depGraph {
+	g := &depGraph{Edges: []graphEdge{}}
+	for _, from := range graph.Nodes() {
+		for _, to := range graph.Edges(from) {
+			label := ""
+			if graph.Kind(from, to) == util.CgoInclude {
+				label = util.CgoInclude.String()
+			}
+			g.Edges = append(g.Edges, graphEdge{from, to, label})
+		}
+	}
+	g.collectNodes()
+	return g
+}
+
+func (g *depGraph) collectNodes() {
+	nodes := util.Packages{}
+	for _, n := range g.Nodes {
+		nodes[n] = true
+	}
+	for _, e := range g.Edges {
+		nodes[e.From] = true
+		nodes[e.To] = true
+	}
+	g.Nodes = []string{}
+	for n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Strings(g.Nodes)
+	sort.Sort(byEdge(g.Edges))
+}
+
+// under returns the subgraph reachable from the dependency (any node that is the dependency or under it)
+func (g *depGraph) under(dep string) *depGraph {
+	reached := util.Packages{}
+	queue := []string{}
+	for _, n := range g.Nodes {
+		if n == dep || strings.HasPrefix(n, dep+"/") {
+			reached[n] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range g.Edges {
+			if e.From == n && !reached[e.To] {
+				reached[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+	sub := &depGraph{Edges: []graphEdge{}}
+	for n := range reached {
+		sub.Nodes = append(sub.Nodes, n)
+	}
+	for _, e := range g.Edges {
+		if reached[e.From] {
+			sub.Edges = append(sub.Edges, e)
+		}
+	}
+	sub.collectNodes()
+	return sub
+}
+
+func (g *depGraph) printDot() {
+	fmt.Println("digraph trash {")
+	for _, n := range g.Nodes {
+		fmt.Printf("  %q;\n", n)
+	}
+	for _, e := range g.Edges {
+		if e.Label != "" {
+			fmt.Printf("  %q -> %q [label=%q];\n", e.From, e.To, e.Label)
+		} else {
+			fmt.Printf("  %q -> %q;\n", e.From, e.To)
+		}
+	}
+	fmt.Println("}")
+}
+
+type byEdge []graphEdge
+
+func (e byEdge) Len() int { return len(e) }
+func (e byEdge) Less(i, j int) bool {
+	if e[i].From != e[j].From {
+		return e[i].From < e[j].From
+	}
+	return e[i].To < e[j].To
+}
+func (e byEdge) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
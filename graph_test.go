@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestRepoGraph(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf, err := conf.ParseBytes("vendor.conf", []byte(`example.com/proj
+example.com/a v1 transitive=true
+example.com/b v1
+`))
+	assert.Nil(err)
+	lock := &conf.Conf{Imports: []conf.Import{
+		{Package: "example.com/a", Version: "v1"},
+		{Package: "example.com/b", Version: "v1"},
+		{Package: "example.com/c", Version: "v2", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/d", Version: "v3", From: "example.com/c/Godeps/Godeps.json"},
+	}}
+	lock.Dedupe()
+
+	g := repoGraph("example.com/proj", trashConf, lock)
+	assert.Equal([]string{"example.com/a", "example.com/b", "example.com/c", "example.com/d", "example.com/proj"}, g.Nodes)
+	assert.Equal([]graphEdge{
+		{"example.com/a", "example.com/c", "vendor.conf"},
+		{"example.com/c", "example.com/d", "Godeps/Godeps.json"},
+		{"example.com/proj", "example.com/a", "vendor.conf"},
+		{"example.com/proj", "example.com/b", "vendor.conf"},
+	}, g.Edges)
+
+	g = repoGraph("example.com/proj", &conf.Conf{Imports: trashConf.Imports}, lock)
+	assert.Equal(graphEdge{"example.com/proj", "example.com/a", "vendor.conf"}, g.Edges[2], "a conf with no file is labeled like the default one")
+
+	sub := g.under("example.com/c")
+	assert.Equal([]string{"example.com/c", "example.com/d"}, sub.Nodes)
+	assert.Len(sub.Edges, 1)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+)
+
+var lintCommand = cli.Command{
+	Name:   "lint",
+	Usage:  "Check the vendored packages list for problems",
+	Action: lint,
+}
+
+func lint(c *cli.Context) error {
+	dir, trashDir, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	vendorDir := path.Join(dir, c.GlobalString("target"))
+
+	linter := conf.Linter{
+		Exists: func(pkg string) bool {
+			for _, d := range []string{vendorDir, path.Join(trashDir, "src")} {
+				if _, err := os.Stat(path.Join(d, pkg)); err == nil {
+					return true
+				}
+			}
+			return false
+		},
+		IsBranch: func(i conf.Import) bool {
+			defer os.Chdir(dir)
+			repoDir := path.Join(trashDir, "src", i.Package)
+			if err := os.Chdir(repoDir); err != nil || !isCurrentDirARepo(trashDir) {
+				logrus.Debugf("No cached repo for '%s': not checking if '%s' is a branch", i.Package, i.Version)
+				return false
+			}
+			return isBranch(remoteName(i.Repo), i.Version)
+		},
+	}
+	findings, err := linter.Lint(trashConf.ConfFile())
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+
+	errors := 0
+	for _, f := range findings {
+		fmt.Println(f)
+		if f.Severity == conf.Error {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return cli.NewExitError(fmt.Sprintf("%s: %d error(s), %d warning(s)", trashConf.ConfFile(), errors, len(findings)-errors), 1)
+	}
+	logrus.Infof("%s: %d warning(s)", trashConf.ConfFile(), len(findings))
+	return nil
+}
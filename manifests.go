@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Masterminds/glide/cfg"
+	glideutil "github.com/Masterminds/glide/util"
+
+	"github.com/rancher/trash/conf"
+)
+
+// manifestReader reads the dependencies a repo's manifest lists
+type manifestReader struct {
+	// file is the manifest's path in the repo
+	file string
+	read func(data []byte) ([]conf.Import, error)
+}
+
+// manifestReaders are tried in order, and the first manifest a repo has is the one its transitive imports come from:
+// lock files come first, as they pin what the looser manifests only constrain. Conf files (confFiles) come last,
+// and are read by parseTransitiveVendor, as their own transitive imports are followed too.
+var manifestReaders = []manifestReader{
+	{"trash.lock", readTrashLock},
+	{"Gopkg.lock", readConfImports("Gopkg.lock")},
+	{"glide.lock", readGlideLock},
+	{"vendor/vendor.json", readVendorJSON},
+	{"Godeps/Godeps.json", readConfImports("Godeps/Godeps.json")},
+}
+
+// readManifest reads the first manifest the repo has at the commit: it returns the manifest's path in the repo
+// ("" if there's none) and the dependencies it lists
+func readManifest(repoDir, commit string) (string, []conf.Import, error) {
+	manifests := []string{}
+	for _, r := range manifestReaders {
+		manifests = append(manifests, r.file)
+	}
+	files, err := filesAt(repoDir, commit, manifests)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, r := range manifestReaders {
+		if !files[r.file] {
+			continue
+		}
+		data, err := showFile(repoDir, commit, r.file)
+		if err != nil {
+			return "", nil, err
+		}
+		imports, err := r.read(data)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not read %s of '%s' at %s: %s", r.file, repoDir, commit, err)
+		}
+		return r.file, imports, nil
+	}
+	return "", nil, nil
+}
+
+// filesAt tells which of the files the repo has at the commit, without checking it out
+func filesAt(repoDir, commit string, files []string) (map[string]bool, error) {
+	args := append([]string{"-C", repoDir, "ls-tree", "-r", "--name-only", commit, "--"}, files...)
+	bytes, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("`git %s` failed: %s", strings.Join(args, " "), err)
+	}
+	found := map[string]bool{}
+	for _, f := range strings.Split(string(bytes), "\n") {
+		if f = strings.TrimSpace(f); f != "" {
+			found[f] = true
+		}
+	}
+	return found, nil
+}
+
+// showFile is the contents of the file at the commit, read from the git objects
+func showFile(repoDir, commit, file string) ([]byte, error) {
+	bytes, err := exec.Command("git", "-C", repoDir, "show", commit+":"+file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("`git -C %s show %s:%s` failed: %s", repoDir, commit, file, err)
+	}
+	return bytes, nil
+}
+
+// readTrashLock takes the commits trash.lock records: the lock lists the repo's transitive imports already
+func readTrashLock(data []byte) ([]conf.Import, error) {
+	lock, err := conf.ParseBytes("trash.lock", data)
+	if err != nil {
+		return nil, err
+	}
+	imports := []conf.Import{}
+	for _, l := range lock.Imports {
+		i := conf.Import{Package: l.Package, Version: l.Version, Repo: l.Repo}
+		if l.Commit != "" {
+			i.Version = l.Commit
+		}
+		imports = append(imports, i)
+	}
+	return imports, nil
+}
+
+// readConfImports reads the imports of a file conf.Parse knows
+func readConfImports(file string) func(data []byte) ([]conf.Import, error) {
+	return func(data []byte) ([]conf.Import, error) {
+		c, err := conf.ParseBytes(file, data)
+		if err != nil {
+			return nil, err
+		}
+		return c.Imports, nil
+	}
+}
+
+// readGlideLock reads the imports of glide.lock, leaving out the test imports
+func readGlideLock(data []byte) ([]conf.Import, error) {
+	lock, err := cfg.LockfileFromYaml(data)
+	if err != nil {
+		return nil, err
+	}
+	imports := []conf.Import{}
+	for _, l := range lock.Imports {
+		imports = append(imports, conf.Import{Package: l.Name, Version: l.Version, Repo: l.Repository})
+	}
+	return imports, nil
+}
+
+// vendorJSON is govendor's vendor/vendor.json
+type vendorJSON struct {
+	RootPath string `json:"rootPath"`
+	// Ignore lists the build tags and "test" (for test files) govendor leaves out, separated by spaces
+	Ignore  string `json:"ignore"`
+	Package []struct {
+		Path     string `json:"path"`
+		Origin   string `json:"origin"`
+		Revision string `json:"revision"`
+	} `json:"package"`
+}
+
+// readVendorJSON reads govendor's vendor.json, which lists packages: they are grouped by repo.
+// An origin outside of a vendor dir is a fork the repo is fetched from.
+func readVendorJSON(data []byte) ([]conf.Import, error) {
+	v := vendorJSON{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	imports := []conf.Import{}
+	seen := map[string]bool{}
+	for _, p := range v.Package {
+		pkg, _ := glideutil.NormalizeName(p.Path)
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		i := conf.Import{Package: pkg, Version: p.Revision}
+		if p.Origin != "" && !strings.Contains(p.Origin, "/vendor/") {
+			if origin, _ := glideutil.NormalizeName(p.Origin); origin != pkg {
+				i.Repo = "https://" + origin
+			}
+		}
+		imports = append(imports, i)
+	}
+	return imports, nil
+}
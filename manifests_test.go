@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestReadTrashLock(t *testing.T) {
+	assert := require.New(t)
+
+	imports, err := readTrashLock([]byte(`package: github.com/foo/dep
+import:
+- package: github.com/foo/a
+  version: ^1.2
+  ref: v1.2.3
+  commit: 0123456789abcdef0123456789abcdef01234567
+- package: github.com/foo/b
+  version: v0.1.0
+  repo: https://github.com/fork/b.git
+  from: github.com/foo/a/vendor.conf
+`))
+	assert.Nil(err)
+	assert.Equal([]conf.Import{
+		{Package: "github.com/foo/a", Version: "0123456789abcdef0123456789abcdef01234567"},
+		{Package: "github.com/foo/b", Version: "v0.1.0", Repo: "https://github.com/fork/b.git"},
+	}, imports)
+}
+
+func TestReadGopkgLock(t *testing.T) {
+	assert := require.New(t)
+
+	imports, err := readConfImports("Gopkg.lock")([]byte(`# This file is autogenerated, do not edit; changes may be undone by the next 'dep ensure'.
+
+
+[[projects]]
+  branch = "master"
+  name = "github.com/foo/a"
+  packages = [
+    ".",
+    "sub"
+  ]
+  revision = "0123456789abcdef0123456789abcdef01234567"
+
+[[projects]]
+  name = "github.com/foo/b"
+  packages = ["."]
+  revision = "89abcdef0123456789abcdef0123456789abcdef"
+  source = "https://github.com/fork/b.git"
+  version = "v1.0.0"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  inputs-digest = "abc"
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`))
+	assert.Nil(err)
+	assert.Equal([]conf.Import{
+		{Package: "github.com/foo/a", Version: "0123456789abcdef0123456789abcdef01234567"},
+		{Package: "github.com/foo/b", Version: "89abcdef0123456789abcdef0123456789abcdef", Repo: "https://github.com/fork/b.git"},
+	}, imports)
+
+	_, err = readConfImports("Gopkg.lock")([]byte("[[projects]]\n  name = \"github.com/foo/a\"\n"))
+	assert.NotNil(err)
+}
+
+func TestReadGlideLock(t *testing.T) {
+	assert := require.New(t)
+
+	imports, err := readGlideLock([]byte(`hash: 1a2b3c
+updated: 2017-03-01T10:00:00.000000000Z
+imports:
+- name: github.com/foo/a
+  version: 0123456789abcdef0123456789abcdef01234567
+  subpackages:
+  - sub
+- name: github.com/foo/b
+  version: v1.0.0
+  repo: https://github.com/fork/b.git
+testImports:
+- name: github.com/foo/test
+  version: 89abcdef0123456789abcdef0123456789abcdef
+`))
+	assert.Nil(err)
+	assert.Equal([]conf.Import{
+		{Package: "github.com/foo/a", Version: "0123456789abcdef0123456789abcdef01234567"},
+		{Package: "github.com/foo/b", Version: "v1.0.0", Repo: "https://github.com/fork/b.git"},
+	}, imports)
+}
+
+func TestReadVendorJSON(t *testing.T) {
+	assert := require.New(t)
+
+	imports, err := readVendorJSON([]byte(`{
+	"comment": "",
+	"ignore": "test",
+	"package": [
+		{"checksumSHA1": "x", "path": "github.com/foo/a", "revision": "0123456789abcdef0123456789abcdef01234567", "revisionTime": "2017-01-01T00:00:00Z"},
+		{"checksumSHA1": "y", "path": "github.com/foo/a/sub", "revision": "0123456789abcdef0123456789abcdef01234567"},
+		{"checksumSHA1": "z", "origin": "github.com/fork/b", "path": "github.com/foo/b", "revision": "89abcdef0123456789abcdef0123456789abcdef"},
+		{"checksumSHA1": "w", "origin": "github.com/foo/x/vendor/github.com/foo/c", "path": "github.com/foo/c/sub", "revision": "456789abcdef0123456789abcdef0123456789ab"}
+	],
+	"rootPath": "github.com/foo/dep"
+}`))
+	assert.Nil(err)
+	assert.Equal([]conf.Import{
+		{Package: "github.com/foo/a", Version: "0123456789abcdef0123456789abcdef01234567"},
+		{Package: "github.com/foo/b", Version: "89abcdef0123456789abcdef0123456789abcdef", Repo: "https://github.com/fork/b"},
+		{Package: "github.com/foo/c", Version: "456789abcdef0123456789abcdef0123456789ab"},
+	}, imports)
+}
+
+func TestReadGodeps(t *testing.T) {
+	assert := require.New(t)
+
+	imports, err := readConfImports("Godeps/Godeps.json")([]byte(`{
+	"ImportPath": "github.com/foo/dep",
+	"GoVersion": "go1.7",
+	"Deps": [
+		{"ImportPath": "github.com/foo/a", "Comment": "v1.0.0", "Rev": "0123456789abcdef0123456789abcdef01234567"},
+		{"ImportPath": "github.com/foo/a/sub", "Rev": "0123456789abcdef0123456789abcdef01234567"},
+		{"ImportPath": "github.com/foo/b/pkg", "Rev": "89abcdef0123456789abcdef0123456789abcdef"}
+	]
+}`))
+	assert.Nil(err)
+	assert.Equal([]conf.Import{
+		{Package: "github.com/foo/a", Version: "0123456789abcdef0123456789abcdef01234567"},
+		{Package: "github.com/foo/b", Version: "89abcdef0123456789abcdef0123456789abcdef"},
+	}, imports)
+}
+
+func TestReadManifestPrecedence(t *testing.T) {
+	assert := require.New(t)
+
+	repoDir, err := ioutil.TempDir("", "trash-manifests")
+	assert.Nil(err)
+	defer os.RemoveAll(repoDir)
+
+	git := func(args ...string) string {
+		bytes, err := exec.Command("git", append([]string{"-C", repoDir, "-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...).CombinedOutput()
+		assert.Nil(err, string(bytes))
+		return strings.TrimSpace(string(bytes))
+	}
+	commit := func(files map[string]string) string {
+		for file, content := range files {
+			assert.Nil(os.MkdirAll(filepath.Join(repoDir, filepath.Dir(file)), 0755))
+			assert.Nil(ioutil.WriteFile(filepath.Join(repoDir, file), []byte(content), 0644))
+		}
+		git("add", "-A")
+		git("commit", "-q", "--allow-empty", "-m", "manifests")
+		return git("rev-parse", "HEAD")
+	}
+	git("init", "-q")
+
+	empty := commit(nil)
+	manifest, imports, err := readManifest(repoDir, empty)
+	assert.Nil(err)
+	assert.Equal("", manifest)
+	assert.Len(imports, 0)
+
+	glide := commit(map[string]string{
+		"Godeps/Godeps.json": `{"Deps": [{"ImportPath": "github.com/foo/a", "Rev": "godeps"}]}`,
+		"glide.lock":         "imports:\n- name: github.com/foo/a\n  version: glide\n",
+	})
+	withLock := commit(map[string]string{"trash.lock": "import:\n- package: github.com/foo/a\n  version: v1\n  commit: trash\n"})
+	git("rm", "-q", "trash.lock", "glide.lock")
+	broken := commit(map[string]string{"vendor/vendor.json": "{"})
+
+	// the working tree is not what's read
+	assert.Nil(ioutil.WriteFile(filepath.Join(repoDir, "Gopkg.lock"), []byte("[[projects]]"), 0644))
+
+	manifest, imports, err = readManifest(repoDir, glide)
+	assert.Nil(err)
+	assert.Equal("glide.lock", manifest)
+	assert.Equal("glide", imports[0].Version)
+
+	manifest, imports, err = readManifest(repoDir, withLock)
+	assert.Nil(err)
+	assert.Equal("trash.lock", manifest)
+	assert.Equal("trash", imports[0].Version)
+
+	_, _, err = readManifest(repoDir, broken)
+	assert.NotNil(err)
+
+	_, _, err = readManifest(repoDir, "0123456789abcdef0123456789abcdef01234567")
+	assert.NotNil(err)
+}
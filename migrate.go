@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/glide/cfg"
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+)
+
+var migrateCommand = cli.Command{
+	Name:  "migrate",
+	Usage: "Create the conf file from the manifest and lock file of glide, godep, dep or govendor",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "from",
+			Usage: "The tool to migrate from: " + strings.Join(migrationTools(), ", "),
+		},
+		cli.StringFlag{
+			Name:  "package",
+			Usage: "The project's root package, if the manifest doesn't have it (guessed from GOPATH if not set)",
+		},
+		cli.BoolFlag{
+			Name:  "yaml",
+			Usage: "Write the conf file in YAML instead of the flat format",
+		},
+		cli.BoolFlag{
+			Name:  "vendor",
+			Usage: "Vendor the imports and check that the vendor dir is the same as the one the other tool made",
+		},
+	},
+	Action: migrate,
+}
+
+// migration is how to read another tool's files: its manifest as a conf, and the exact versions its lock file pins
+type migration struct {
+	// manifests are tried in order
+	manifests []string
+	read      func(file string) (*conf.Conf, []string, error)
+	// lock is "" if the manifest pins the versions itself
+	lock     string
+	readLock func(data []byte) ([]conf.Import, error)
+}
+
+var migrations = map[string]migration{
+	"glide":    {[]string{"glide.yaml", "glide.yml"}, readForeignConf, "glide.lock", readGlideLockWithTests},
+	"dep":      {[]string{"Gopkg.toml"}, readForeignConf, "Gopkg.lock", readConfImports("Gopkg.lock")},
+	"godep":    {[]string{"Godeps/Godeps.json"}, readForeignConf, "", nil},
+	"govendor": {[]string{"vendor/vendor.json"}, readGovendor, "", nil},
+}
+
+func migrationTools() []string {
+	tools := []string{}
+	for t := range migrations {
+		tools = append(tools, t)
+	}
+	sort.Strings(tools)
+	return tools
+}
+
+func migrate(c *cli.Context) error {
+	dir, trashDir, err := prepareDir(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	confFile := c.GlobalString("file")
+	if existing, err := findConfFile(confFile); err == nil && !conf.IsGlideFile(existing) {
+		err := fmt.Errorf("'%s' already exists: remove it to migrate again", existing)
+		logrus.Error(err)
+		return err
+	}
+	trashConf, untranslated, err := readMigration(c.String("from"))
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	if c.String("package") != "" {
+		trashConf.Package = c.String("package")
+	}
+	if trashConf.Package == "" {
+		trashConf.Package = guessRootPackage(dir)
+	}
+	trashConf.SetYAML(c.Bool("yaml"))
+	if err := trashConf.Dump(confFile); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	logrus.Infof("Created '%s' with %d import(s)", confFile, len(trashConf.Imports))
+	printUntranslated(untranslated)
+
+	if !c.Bool("vendor") {
+		logrus.Infof("Run `trash` to vendor them, or `trash migrate --vendor` to compare the result with the old vendor dir")
+		return nil
+	}
+	// vendor what the conf file says
+	if trashConf, err = conf.Parse(confFile); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	if err := vendorMigration(trashDir, dir, c.GlobalString("target"), trashConf, c.GlobalBool("insecure")); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	return nil
+}
+
+// readMigration reads the files of the tool as a trash conf, with the imports pinned to the versions in the lock file.
+// It also returns what couldn't be translated.
+func readMigration(tool string) (*conf.Conf, []string, error) {
+	m, ok := migrations[tool]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown tool '%s' to migrate from: use --from with one of %s", tool, strings.Join(migrationTools(), ", "))
+	}
+	manifest := ""
+	for _, f := range m.manifests {
+		if _, err := os.Stat(f); err == nil {
+			manifest = f
+			break
+		}
+	}
+	if manifest == "" {
+		return nil, nil, fmt.Errorf("no %s here: is this a %s project?", strings.Join(m.manifests, " or "), tool)
+	}
+	logrus.Infof("Reading '%s'", manifest)
+	trashConf, untranslated, err := m.read(manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read '%s': %s", manifest, err)
+	}
+	if m.lock == "" {
+		return trashConf, untranslated, nil
+	}
+	data, err := ioutil.ReadFile(m.lock)
+	if os.IsNotExist(err) {
+		untranslated = append(untranslated, fmt.Sprintf("there's no %s: the versions are the ranges of %s, not exact pins", m.lock, manifest))
+		return trashConf, untranslated, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	logrus.Infof("Reading '%s'", m.lock)
+	locked, err := m.readLock(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read '%s': %s", m.lock, err)
+	}
+	return trashConf, append(untranslated, pinToLock(trashConf, locked, m.lock)...), nil
+}
+
+// readForeignConf reads a manifest conf.Parse knows
+func readForeignConf(file string) (*conf.Conf, []string, error) {
+	trashConf, err := conf.Parse(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trashConf, trashConf.Untranslated(), nil
+}
+
+// readGovendor reads govendor's vendor/vendor.json, which pins the packages it lists
+func readGovendor(file string) (*conf.Conf, []string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	imports, err := readVendorJSON(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := vendorJSON{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, nil, err
+	}
+	untranslated := []string{}
+	for _, ignore := range strings.Fields(v.Ignore) {
+		if ignore == "test" {
+			continue // trash leaves out the test files of vendored packages too
+		}
+		untranslated = append(untranslated, fmt.Sprintf("ignore '%s' in %s is not honored: trash doesn't filter files by build tag", ignore, file))
+	}
+	trashConf := &conf.Conf{Package: v.RootPath, Imports: imports}
+	trashConf.Dedupe()
+	return trashConf, untranslated, nil
+}
+
+// readGlideLockWithTests reads the imports of glide.lock, along with the test imports, marked test-only
+func readGlideLockWithTests(data []byte) ([]conf.Import, error) {
+	lock, err := cfg.LockfileFromYaml(data)
+	if err != nil {
+		return nil, err
+	}
+	imports := []conf.Import{}
+	for _, l := range lock.Imports {
+		imports = append(imports, conf.Import{Package: l.Name, Version: l.Version, Repo: l.Repository})
+	}
+	for _, l := range lock.DevImports {
+		imports = append(imports, conf.Import{Package: l.Name, Version: l.Version, Repo: l.Repository, Options: conf.Options{Test: true}})
+	}
+	return imports, nil
+}
+
+// pinToLock pins the imports of the conf to the versions of the lock file, and adds the other locked imports:
+// the other tools vendor the whole dependency graph in the project's vendor dir, as trash does with the conf imports.
+// Overrides of locked packages are dropped, as the lock pins them already. It returns what couldn't be pinned.
+func pinToLock(trashConf *conf.Conf, locked []conf.Import, lockFile string) []string {
+	untranslated := []string{}
+	lockMap := map[string]conf.Import{}
+	for _, l := range locked {
+		lockMap[l.Package] = l
+	}
+	imports := []conf.Import{}
+	for _, i := range trashConf.Imports {
+		l, ok := lockMap[i.Package]
+		if !ok {
+			untranslated = append(untranslated, fmt.Sprintf("'%s' is not in %s: kept its version '%s'", i.Package, lockFile, i.Version))
+			imports = append(imports, i)
+			continue
+		}
+		delete(lockMap, i.Package)
+		i.Version = l.Version
+		if i.Repo == "" {
+			i.Repo = l.Repo
+		}
+		imports = append(imports, i)
+	}
+	for _, l := range locked {
+		if _, ok := lockMap[l.Package]; ok {
+			imports = append(imports, l)
+		}
+	}
+	trashConf.Imports = imports
+	trashConf.Dedupe()
+
+	overrides := []conf.Import{}
+	for _, o := range trashConf.Overrides {
+		if _, ok := trashConf.Get(o.Package); ok {
+			logrus.Infof("Dropping the override of '%s': %s pins it", o.Package, lockFile)
+			continue
+		}
+		overrides = append(overrides, o)
+	}
+	trashConf.Overrides = overrides
+	return untranslated
+}
+
+func printUntranslated(untranslated []string) {
+	if len(untranslated) == 0 {
+		fmt.Println("Everything was translated")
+		return
+	}
+	fmt.Println("Not translated:")
+	for _, u := range untranslated {
+		fmt.Println("  " + u)
+	}
+}
+
+// vendorMigration vendors the migrated conf and compares the vendored packages with the ones in the old vendor dir.
+// trash vendors into a scratch dir next to it, so that the old vendor dir stays as it is whatever happens. The old packages
+// the project doesn't import are left out, as usual. If trash vendored the same packages, its vendor dir replaces the old one
+// and trash.lock is written: else the old one is kept, with no trash.lock, and trash's is left in the scratch dir for a look.
+func vendorMigration(trashDir, dir, targetDir string, trashConf *conf.Conf, insecure bool) error {
+	vendorDir := path.Join(dir, targetDir)
+	if _, err := os.Stat(vendorDir); os.IsNotExist(err) {
+		logrus.Infof("Vendoring the imports: there's no '%s' to compare with", targetDir)
+		return trash(false, false, false, insecure, trashDir, dir, targetDir, trashConf)
+	}
+	// dot dirs are not looked at for the project's imports, and the old vendor dir must not be either
+	scratchDir := path.Join(path.Dir(targetDir), "."+path.Base(targetDir)+".trash")
+	migratedDir := path.Join(dir, scratchDir)
+	if err := os.RemoveAll(migratedDir); err != nil {
+		return err
+	}
+	skipDirs = []string{targetDir}
+	err := populate(false, false, false, insecure, trashDir, dir, scratchDir, trashConf)
+	skipDirs = nil
+	if err != nil {
+		return err
+	}
+
+	onlyOld, onlyNew, changed, err := diffVendorDirs(vendorDir, migratedDir)
+	if err != nil {
+		return err
+	}
+	for _, p := range onlyOld {
+		fmt.Println("- " + p)
+	}
+	if len(onlyOld) > 0 {
+		logrus.Infof("%d package(s) of the old '%s' are left out: the project doesn't import them", len(onlyOld), targetDir)
+	}
+	if len(onlyNew)+len(changed) == 0 {
+		logrus.Infof("trash vendored the same packages as '%s' has: replacing it", targetDir)
+		if err := os.RemoveAll(vendorDir); err != nil {
+			return err
+		}
+		if err := os.Rename(migratedDir, vendorDir); err != nil {
+			return err
+		}
+		return writeLock(dir, targetDir, trashConf)
+	}
+	for _, p := range onlyNew {
+		fmt.Println("+ " + p)
+	}
+	for _, p := range changed {
+		fmt.Println("~ " + p)
+	}
+	return fmt.Errorf("the packages trash vendored are not the same as in '%s': kept it, trash's are in '%s'", targetDir, scratchDir)
+}
+
+// diffVendorDirs compares the Go packages of two vendor dirs: their non-test .go files.
+// It returns the packages only the old one has, the ones only the new one has, and the ones with different files.
+func diffVendorDirs(oldDir, newDir string) (onlyOld, onlyNew, changed []string, err error) {
+	oldPackages, err := vendorDigests(oldDir)
+	if err != nil {
+		return
+	}
+	newPackages, err := vendorDigests(newDir)
+	if err != nil {
+		return
+	}
+	for p, digest := range oldPackages {
+		newDigest, ok := newPackages[p]
+		switch {
+		case !ok:
+			onlyOld = append(onlyOld, p)
+		case newDigest != digest:
+			changed = append(changed, p)
+		}
+	}
+	for p := range newPackages {
+		if _, ok := oldPackages[p]; !ok {
+			onlyNew = append(onlyNew, p)
+		}
+	}
+	sort.Strings(onlyOld)
+	sort.Strings(onlyNew)
+	sort.Strings(changed)
+	return
+}
+
+// vendorDigests maps the packages of a vendor dir to the digest of their non-test .go files
+func vendorDigests(vendorDir string) (map[string]string, error) {
+	digests := map[string]string{}
+	err := filepath.Walk(vendorDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		digest, err := packageDigest(p)
+		if err != nil || digest == "" {
+			return err
+		}
+		pkg, err := filepath.Rel(vendorDir, p)
+		digests[pkg] = digest
+		return err
+	})
+	return digests, err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestPinToLock(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf := &conf.Conf{
+		Imports: []conf.Import{
+			{Package: "example.com/a", Version: "^1.0.0", Options: conf.Options{Test: true}},
+			{Package: "example.com/b", Version: "develop", Repo: "https://example.com/fork/b"},
+			{Package: "example.com/c", Version: "v2.0.0"},
+		},
+		Overrides: []conf.Import{
+			{Package: "example.com/d", Version: "=0.3.1"},
+			{Package: "example.com/e", Version: "v1"},
+		},
+	}
+	untranslated := pinToLock(trashConf, []conf.Import{
+		{Package: "example.com/a", Version: "aaaa", Repo: "https://example.com/a"},
+		{Package: "example.com/b", Version: "bbbb", Repo: "https://example.com/b"},
+		{Package: "example.com/d", Version: "dddd"},
+	}, "Gopkg.lock")
+
+	assert.Equal([]conf.Import{
+		{Package: "example.com/a", Version: "aaaa", Repo: "https://example.com/a", Options: conf.Options{Test: true}},
+		{Package: "example.com/b", Version: "bbbb", Repo: "https://example.com/fork/b"},
+		{Package: "example.com/c", Version: "v2.0.0"},
+		{Package: "example.com/d", Version: "dddd"},
+	}, trashConf.Imports)
+	assert.Equal([]conf.Import{{Package: "example.com/e", Version: "v1"}}, trashConf.Overrides, "the lock pins example.com/d")
+	assert.Equal([]string{"'example.com/c' is not in Gopkg.lock: kept its version 'v2.0.0'"}, untranslated)
+}
+
+func TestReadMigration(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "trash-migrate")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	assert.Nil(os.Chdir(dir))
+
+	_, _, err = readMigration("npm")
+	assert.NotNil(err)
+	_, _, err = readMigration("glide")
+	assert.NotNil(err, "no glide.yaml")
+
+	assert.Nil(ioutil.WriteFile("glide.yaml", []byte(`package: example.com/proj
+import:
+- package: example.com/a
+  version: ^1.0.0
+  os:
+  - linux
+`), 0644))
+	trashConf, untranslated, err := readMigration("glide")
+	assert.Nil(err)
+	assert.Equal([]conf.Import{{Package: "example.com/a", Version: "^1.0.0"}}, trashConf.Imports)
+	assert.Len(untranslated, 2)
+	assert.Contains(untranslated[0], "only for os linux")
+	assert.Contains(untranslated[1], "there's no glide.lock")
+
+	assert.Nil(ioutil.WriteFile("glide.lock", []byte(`hash: x
+updated: 2017-01-01T00:00:00Z
+imports:
+- name: example.com/a
+  version: aaaa
+- name: example.com/b
+  version: bbbb
+testImports:
+- name: example.com/t
+  version: tttt
+`), 0644))
+	trashConf, untranslated, err = readMigration("glide")
+	assert.Nil(err)
+	assert.Equal("example.com/proj", trashConf.Package)
+	assert.Equal([]conf.Import{
+		{Package: "example.com/a", Version: "aaaa"},
+		{Package: "example.com/b", Version: "bbbb"},
+		{Package: "example.com/t", Version: "tttt", Options: conf.Options{Test: true}},
+	}, trashConf.Imports)
+	assert.Len(untranslated, 1)
+
+	assert.Nil(os.MkdirAll("vendor", 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join("vendor", "vendor.json"), []byte(`{
+	"rootPath": "example.com/proj",
+	"ignore": "test appengine",
+	"package": [
+		{"path": "github.com/foo/a/sub", "revision": "aaaa"},
+		{"path": "github.com/foo/a", "revision": "aaaa"}
+	]
+}`), 0644))
+	trashConf, untranslated, err = readMigration("govendor")
+	assert.Nil(err)
+	assert.Equal("example.com/proj", trashConf.Package)
+	assert.Equal([]conf.Import{{Package: "github.com/foo/a", Version: "aaaa"}}, trashConf.Imports)
+	assert.Equal([]string{"ignore 'appengine' in vendor/vendor.json is not honored: trash doesn't filter files by build tag"}, untranslated)
+}
+
+func TestDiffVendorDirs(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "trash-migrate")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	write := func(file, content string) {
+		assert.Nil(os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755))
+		assert.Nil(ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	}
+	write("old/example.com/a/a.go", "package a")
+	write("old/example.com/a/a_test.go", "package a")
+	write("old/example.com/b/b.go", "package b")
+	write("old/example.com/c/c.go", "package c")
+	write("old/example.com/unused/u.go", "package unused")
+	write("new/example.com/a/a.go", "package a")
+	write("new/example.com/a/README", "a")
+	write("new/example.com/b/b.go", "package b // changed")
+	write("new/example.com/c/c.go", "package c")
+	write("new/example.com/c/c_linux.go", "package c")
+	write("new/example.com/d/d.go", "package d")
+
+	onlyOld, onlyNew, changed, err := diffVendorDirs(filepath.Join(dir, "old"), filepath.Join(dir, "new"))
+	assert.Nil(err)
+	assert.Equal([]string{"example.com/unused"}, onlyOld)
+	assert.Equal([]string{"example.com/d"}, onlyNew)
+	assert.Equal([]string{"example.com/b", "example.com/c"}, changed)
+}
+
+func TestVendorMigration(t *testing.T) {
+	assert := require.New(t)
+
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+
+	root := writeTree(t, map[string]string{
+		"up/lib/lib.go":               "package lib\n",
+		"up/unused/u.go":              "package unused\n",
+		"cache/src/example.com/.keep": "",
+		"proj/main.go":                "package main\n\nimport _ \"example.com/a/lib\"\n",
+		// only the old vendor dir imports example.com/a/unused
+		"proj/vendor/example.com/a/lib/lib.go": "package lib // changed\n",
+		"proj/vendor/example.com/x/x.go":       "package x\n\nimport _ \"example.com/a/unused\"\n",
+	})
+	defer os.RemoveAll(root)
+	git := func(dir string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...)
+		cmd.Dir = dir
+		bytes, err := cmd.CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+	}
+	up, trashDir, dir := filepath.Join(root, "up"), filepath.Join(root, "cache"), filepath.Join(root, "proj")
+	git(up, "init", "-q")
+	git(up, "add", "-A")
+	git(up, "commit", "-q", "-m", "a")
+	git(up, "tag", "v1.0.0")
+	git(root, "clone", "-q", up, filepath.Join(trashDir, "src", "example.com", "a"))
+	newConf := func() *conf.Conf {
+		trashConf, err := conf.ParseBytes("vendor.conf", []byte("example.com/proj\nexample.com/a v1.0.0\n"))
+		assert.Nil(err)
+		return trashConf
+	}
+
+	assert.NotNil(vendorMigration(trashDir, dir, "vendor", newConf(), false))
+	_, err = os.Stat(filepath.Join(dir, "trash.lock"))
+	assert.True(os.IsNotExist(err), "no trash.lock when the packages differ")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "vendor", "example.com", "a", "lib", "lib.go"))
+	assert.Nil(err)
+	assert.Equal("package lib // changed\n", string(data), "the old vendor dir is kept")
+
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "vendor", "example.com", "a", "lib", "lib.go"), []byte("package lib\n"), 0644))
+	assert.Nil(vendorMigration(trashDir, dir, "vendor", newConf(), false))
+	lock, err := conf.Parse(filepath.Join(dir, "trash.lock"))
+	assert.Nil(err)
+	_, ok := lock.Get("example.com/a")
+	assert.True(ok)
+	for f, exists := range map[string]bool{
+		"vendor/example.com/a/lib/lib.go": true,
+		"vendor/example.com/x":            false,
+		"vendor/example.com/a/unused":     false,
+		".vendor.trash":                   false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, f))
+		assert.Equal(exists, err == nil, f)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/semver"
+	"github.com/rancher/trash/util"
+)
+
+var outdatedCommand = cli.Command{
+	Name:  "outdated",
+	Usage: "List the dependencies that have newer upstream versions",
+	Description: `Uses the cached repos, as of the last run (or the remotes with --online).
+   Tag pins are compared with the highest upstream tag, SHA and branch pins are
+   compared with the upstream branch by the number of commits they are behind.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "online",
+			Usage: "Use `git ls-remote` instead of the cached repos",
+		},
+		cli.BoolFlag{
+			Name:  "pre",
+			Usage: "Consider pre-release tags",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the result as JSON",
+		},
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "Also list the up to date dependencies",
+		},
+	},
+	Action: outdated,
+}
+
+type outdatedDep struct {
+	Package string `json:"package"`
+	Current string `json:"current"`
+	Latest  string `json:"latest,omitempty"`
+	// Update is the kind of update: major, minor, patch, pre-release, behind (for SHA and branch pins), up-to-date or unknown
+	Update string `json:"update"`
+	Behind int    `json:"behind,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+func outdated(c *cli.Context) error {
+	dir, trashDir, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	defer os.Chdir(dir)
+	lockedRevisions(false, parseLock(dir), trashConf)
+
+	deps := []outdatedDep{}
+	for _, i := range trashConf.Imports {
+		var d outdatedDep
+		if c.Bool("online") {
+			d = outdatedOnline(trashDir, i, c.Bool("pre"))
+		} else {
+			d = outdatedCached(trashDir, i, c.Bool("pre"))
+		}
+		if d.Update != "up-to-date" || c.Bool("all") {
+			deps = append(deps, d)
+		}
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(deps, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tCURRENT\tLATEST\tUPDATE\tNOTE")
+	for _, d := range deps {
+		update := d.Update
+		if d.Behind > 0 {
+			update = fmt.Sprintf("%d commits behind", d.Behind)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Package, d.Current, d.Latest, update, d.Note)
+	}
+	return w.Flush()
+}
+
+// compareTags fills in the latest tag and, if the current version is a semantic version, the kind of update.
+// For version constraints, the current version is the tag recorded in trash.lock and only the tags with the constraint's prefix count.
+func (d *outdatedDep) compareTags(i conf.Import, tags []string, pre bool) bool {
+	current, prefix := i.Version, ""
+	var constraint *semver.Constraint
+	if semver.IsConstraint(i.Version) {
+		c, err := semver.ParseConstraint(i.Version)
+		if err != nil {
+			d.Note = err.Error()
+			return true
+		}
+		constraint, current, prefix = c, i.Ref, c.Prefix
+		d.Current = displayVersion(i)
+	}
+	latest := semver.Latest(semver.ParsePrefixedTags(tags, prefix), pre)
+	if latest == nil {
+		return false
+	}
+	d.Latest = latest.Original
+	v, err := semver.Parse(strings.TrimPrefix(current, prefix))
+	if err != nil {
+		return constraint != nil
+	}
+	if d.Update = v.Diff(latest); d.Update == "" {
+		d.Update = "up-to-date"
+	} else if constraint != nil && !constraint.Match(latest) {
+		d.Note = fmt.Sprintf("outside of %s: change the version in the conf file", i.Version)
+	}
+	return true
+}
+
+func outdatedCached(trashDir string, i conf.Import, pre bool) outdatedDep {
+	d := outdatedDep{Package: i.Package, Current: i.Version, Update: "unknown"}
+	repoDir := path.Join(trashDir, "src", i.Package)
+	if err := os.Chdir(repoDir); err != nil || !isCurrentDirARepo(trashDir) {
+		d.Note = "not in the cache: run trash first, or use --online"
+		return d
+	}
+	tags := []string{}
+	for l := range util.CmdOutLines(exec.Command("git", "tag", "-l")) {
+		tags = append(tags, strings.TrimSpace(l))
+	}
+	if d.compareTags(i, tags, pre) {
+		return d
+	}
+
+	// SHA pins are compared with the default branch
+	remote := remoteName(i.Repo)
+	from, to, branch := i.Version, "", ""
+	switch {
+	case conf.IsDatePin(i.Version):
+		branch = i.Version[:strings.LastIndex(i.Version, "@")]
+		from, d.Current = i.Commit, displayVersion(i)
+	case isRef(i.Version):
+		_, _, to = refspec(remote, i.Version)
+		from, d.Current = i.Commit, displayVersion(i)
+	case isDefaultBranch(i.Version):
+		// the cache has what was checked out by the last run
+		from, d.Note = "HEAD", "branch pin"
+	case isBranch(remote, i.Version):
+		from, branch, d.Note = "HEAD", i.Version, "branch pin"
+	}
+	if from == "" {
+		d.Note = "not resolved yet: run trash first"
+		return d
+	}
+	if to == "" && branch == "" {
+		var err error
+		if branch, err = defaultBranch(remote); err != nil {
+			d.Note = err.Error()
+			return d
+		}
+	}
+	if to == "" {
+		to = remote + "/" + branch
+	}
+	if conf.IsDatePin(i.Version) {
+		// the latest is the same pin at the date of the branch's newest commit
+		d.Latest = i.Version[:strings.LastIndex(i.Version, "@")+1] + commitDate(to)
+	}
+	bytes, err := exec.Command("git", "rev-list", "--count", from+".."+to).Output()
+	if err != nil {
+		d.Note = fmt.Sprintf("could not count commits %s..%s", from, to)
+		return d
+	}
+	if d.Behind, _ = strconv.Atoi(strings.TrimSpace(string(bytes))); d.Behind > 0 {
+		d.Update = "behind"
+	} else {
+		d.Update = "up-to-date"
+	}
+	return d
+}
+
+func outdatedOnline(trashDir string, i conf.Import, pre bool) outdatedDep {
+	d := outdatedDep{Package: i.Package, Current: i.Version, Update: "unknown"}
+	url := remoteURL(trashDir, i)
+	refs, err := lsRemote(url)
+	if err != nil {
+		d.Note = err.Error()
+		return d
+	}
+	tags := []string{}
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/tags/") {
+			tags = append(tags, strings.TrimPrefix(ref, "refs/tags/"))
+		}
+	}
+	if d.compareTags(i, tags, pre) {
+		return d
+	}
+
+	if conf.IsDatePin(i.Version) || isRef(i.Version) {
+		head := refs["HEAD"]
+		if isRef(i.Version) {
+			_, src, _ := refspec("", i.Version)
+			head = refs[src]
+		} else if branch, _, _ := conf.ParseDatePin(i.Version); branch != "" {
+			head = refs["refs/heads/"+branch]
+		}
+		d.Current = displayVersion(i)
+		switch {
+		case i.Commit == "" || head == "":
+			d.Note = "not resolved yet: run trash first"
+		case head == i.Commit:
+			d.Update = "up-to-date"
+		default:
+			d.Update = "behind"
+			d.Note = "run without --online to count the commits"
+		}
+		return d
+	}
+	if _, ok := refs["refs/heads/"+i.Version]; ok || isDefaultBranch(i.Version) {
+		d.Note = "branch pin: run without --online to count the commits"
+		return d
+	}
+	head := refs["HEAD"]
+	if head != "" && strings.HasPrefix(head, i.Version) {
+		d.Update = "up-to-date"
+	} else if head != "" {
+		d.Update = "behind"
+		d.Note = "run without --online to count the commits"
+	}
+	return d
+}
+
+// remoteURL is the repo URL of the import: its repo, the cached repo's origin or the package path as an https URL
+func remoteURL(trashDir string, i conf.Import) string {
+	if i.Repo != "" {
+		return i.Repo
+	}
+	if bytes, err := exec.Command("git", "-C", path.Join(trashDir, "src", i.Package), "config", "--get", "remote.origin.url").Output(); err == nil {
+		if url := strings.TrimSpace(string(bytes)); url != "" {
+			return url
+		}
+	}
+	return "https://" + i.Package
+}
+
+// lsRemote lists the remote refs with the commits they point to (peeled for annotated tags)
+func lsRemote(url string) (map[string]string, error) {
+	bytes, err := exec.Command("git", "ls-remote", url).Output()
+	if err != nil {
+		return nil, fmt.Errorf("`git ls-remote %s` failed: %s", url, err)
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(string(bytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		sha, ref := fields[0], fields[1]
+		if strings.HasSuffix(ref, "^{}") {
+			refs[strings.TrimSuffix(ref, "^{}")] = sha
+		} else if _, ok := refs[ref]; !ok {
+			refs[ref] = sha
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestCompareTags(t *testing.T) {
+	assert := require.New(t)
+
+	for _, test := range []struct {
+		i        conf.Import
+		tags     []string
+		pre      bool
+		compared bool
+		want     outdatedDep
+	}{
+		{
+			i:        conf.Import{Version: "v1.0.0"},
+			tags:     []string{"v1.0.0", "v1.0.1"},
+			compared: true,
+			want:     outdatedDep{Latest: "v1.0.1", Update: "patch"},
+		},
+		{
+			i:        conf.Import{Version: "v1.0.0"},
+			tags:     []string{"v1.0.0", "v1.0.1", "v1.1.0", "not-a-version"},
+			compared: true,
+			want:     outdatedDep{Latest: "v1.1.0", Update: "minor"},
+		},
+		{
+			i:        conf.Import{Version: "v1.0.0"},
+			tags:     []string{"v1.1.0", "v2.0.0"},
+			compared: true,
+			want:     outdatedDep{Latest: "v2.0.0", Update: "major"},
+		},
+		{
+			i:        conf.Import{Version: "v1.1.0"},
+			tags:     []string{"v1.0.0", "v1.1.0"},
+			compared: true,
+			want:     outdatedDep{Latest: "v1.1.0", Update: "up-to-date"},
+		},
+		{
+			i:        conf.Import{Version: "v1.0.0"},
+			tags:     []string{"v1.0.0", "v1.1.0-rc.1"},
+			compared: true,
+			want:     outdatedDep{Latest: "v1.0.0", Update: "up-to-date"},
+		},
+		{
+			i:        conf.Import{Version: "v1.1.0-rc.1"},
+			tags:     []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0-rc.2"},
+			pre:      true,
+			compared: true,
+			want:     outdatedDep{Latest: "v1.1.0-rc.2", Update: "pre-release"},
+		},
+		{
+			i:        conf.Import{Version: "^1.0.0", Ref: "v1.0.0"},
+			tags:     []string{"v1.0.0", "v1.2.0"},
+			compared: true,
+			want:     outdatedDep{Current: "^1.0.0 (v1.0.0)", Latest: "v1.2.0", Update: "minor"},
+		},
+		{
+			i:        conf.Import{Version: "^1.0.0", Ref: "v1.2.0"},
+			tags:     []string{"v1.0.0", "v1.2.0", "v2.0.0"},
+			compared: true,
+			want:     outdatedDep{Current: "^1.0.0 (v1.2.0)", Latest: "v2.0.0", Update: "major", Note: "outside of ^1.0.0: change the version in the conf file"},
+		},
+		{
+			i:        conf.Import{Version: "api/^1.0.0", Ref: "api/v1.0.0"},
+			tags:     []string{"api/v1.0.0", "api/v1.1.0", "v3.0.0"},
+			compared: true,
+			want:     outdatedDep{Current: "api/^1.0.0 (api/v1.0.0)", Latest: "api/v1.1.0", Update: "minor"},
+		},
+		{
+			i:        conf.Import{Version: "^1.0.0"},
+			tags:     []string{"v1.0.0"},
+			compared: true,
+			want:     outdatedDep{Current: "^1.0.0", Latest: "v1.0.0", Update: "unknown"},
+		},
+		{
+			i:    conf.Import{Version: "master"},
+			tags: []string{"v1.0.0"},
+			want: outdatedDep{Latest: "v1.0.0", Update: "unknown"},
+		},
+		{
+			i:    conf.Import{Version: "a1b2c3d"},
+			tags: []string{"v1.0.0"},
+			want: outdatedDep{Latest: "v1.0.0", Update: "unknown"},
+		},
+		{
+			i:    conf.Import{Version: "v1.0.0"},
+			tags: []string{"release", "latest"},
+			want: outdatedDep{Update: "unknown"},
+		},
+	} {
+		d := outdatedDep{Update: "unknown"}
+		assert.Equal(test.compared, d.compareTags(test.i, test.tags, test.pre), "%v %v", test.i, test.tags)
+		assert.Equal(test.want, d, "%v %v", test.i, test.tags)
+	}
+}
+
+func TestOutdatedCached(t *testing.T) {
+	assert := require.New(t)
+
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	root, err := ioutil.TempDir("", "trash-outdated")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	git := func(dir, date string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		bytes, err := cmd.CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+		return strings.TrimSpace(string(bytes))
+	}
+	up := filepath.Join(root, "up")
+	assert.Nil(os.MkdirAll(up, 0755))
+	git(up, "", "init", "-q")
+	git(up, "", "checkout", "-q", "-b", "master")
+	git(up, "2019-01-01T12:00:00Z", "commit", "-q", "--allow-empty", "-m", "one")
+	git(up, "", "tag", "v1.0.0")
+	first := git(up, "", "rev-parse", "HEAD")
+	git(up, "2019-06-01T12:00:00Z", "commit", "-q", "--allow-empty", "-m", "two")
+	git(up, "", "tag", "v1.1.0")
+	git(up, "2019-09-01T12:00:00Z", "commit", "-q", "--allow-empty", "-m", "three")
+
+	trashDir := filepath.Join(root, "cache")
+	repoDir := filepath.Join(trashDir, "src", "example.com", "x")
+	git(root, "", "clone", "-q", up, repoDir)
+	// the cache has what the last run checked out
+	git(repoDir, "", "checkout", "-q", first)
+
+	for _, test := range []struct {
+		i    conf.Import
+		want outdatedDep
+	}{
+		{
+			i:    conf.Import{Package: "example.com/x", Version: "v1.0.0"},
+			want: outdatedDep{Package: "example.com/x", Current: "v1.0.0", Latest: "v1.1.0", Update: "minor"},
+		},
+		{
+			i:    conf.Import{Package: "example.com/x", Version: "master"},
+			want: outdatedDep{Package: "example.com/x", Current: "master", Latest: "v1.1.0", Update: "behind", Behind: 2, Note: "branch pin"},
+		},
+		{
+			i:    conf.Import{Package: "example.com/x", Version: first[:7]},
+			want: outdatedDep{Package: "example.com/x", Current: first[:7], Latest: "v1.1.0", Update: "behind", Behind: 2},
+		},
+		{
+			i:    conf.Import{Package: "example.com/x", Version: "master@2019-03-01", Commit: first},
+			want: outdatedDep{Package: "example.com/x", Current: "master@2019-03-01 (" + first[:12] + ")", Latest: "master@2019-09-01", Update: "behind", Behind: 2},
+		},
+		{
+			i:    conf.Import{Package: "example.com/x", Version: "master@2019-03-01"},
+			want: outdatedDep{Package: "example.com/x", Current: "master@2019-03-01", Latest: "v1.1.0", Update: "unknown", Note: "not resolved yet: run trash first"},
+		},
+		{
+			i:    conf.Import{Package: "example.com/missing", Version: "v1.0.0"},
+			want: outdatedDep{Package: "example.com/missing", Current: "v1.0.0", Update: "unknown", Note: "not in the cache: run trash first, or use --online"},
+		},
+	} {
+		assert.Equal(test.want, outdatedCached(trashDir, test.i, false), test.i.Version)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/rancher/trash/conf"
+)
+
+// transitiveRules are the conf's override and ignore-transitive entries, and what they did to the dependency graph
+type transitiveRules struct {
+	confFile  string
+	overrides map[string]conf.Import
+	ignores   []string
+	// applied maps the overridden packages to the versions they replaced, ignored the packages that were dropped
+	applied map[string][]string
+	ignored map[string][]string
+	// excludes and packages are the excludes and package= entries of the transitive imports' conf files
+	excludes []string
+	packages []string
+}
+
+func newTransitiveRules(trashConf *conf.Conf) *transitiveRules {
+	r := &transitiveRules{
+		confFile:  trashConf.ConfFile(),
+		overrides: map[string]conf.Import{},
+		ignores:   trashConf.IgnoreTransitive,
+		applied:   map[string][]string{},
+		ignored:   map[string][]string{},
+	}
+	for _, o := range trashConf.Overrides {
+		if _, ok := r.overrides[o.Package]; ok {
+			logrus.Warnf("Package '%s' is overridden more than once in %s: using the first override", o.Package, r.confFile)
+			continue
+		}
+		r.overrides[o.Package] = o
+	}
+	return r
+}
+
+// matchesAny tells if a pattern matches the package: the pattern is a package (covering its subpackages) or a path.Match glob
+func matchesAny(patterns []string, pkg string) bool {
+	for _, p := range patterns {
+		if pkg == p || strings.HasPrefix(pkg, p+"/") {
+			return true
+		}
+		if ok, _ := path.Match(p, pkg); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply overrides the versions and repos of the imports and, if they come from transitive imports, drops the ignored ones
+func (r *transitiveRules) apply(imports []conf.Import, transitive bool) []conf.Import {
+	result := []conf.Import{}
+	for _, i := range imports {
+		if transitive && matchesAny(r.ignores, i.Package) {
+			r.ignored[i.Package] = append(r.ignored[i.Package], i.From)
+			continue
+		}
+		if o, ok := r.overrides[i.Package]; ok {
+			old := versionAndRepo(i)
+			if transitive {
+				old += " from " + i.From
+			}
+			r.applied[i.Package] = append(r.applied[i.Package], old)
+			if o.Version != "" {
+				i.Version = o.Version
+			}
+			if o.Repo != "" {
+				i.Repo = o.Repo
+			}
+			i.Reason = "override in " + r.confFile
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
+// inherit takes the excludes and package= entries of a transitive import's conf file. They can only concern the import's
+// subtree: its own packages and the packages of the repos it imports. The other entries are left out.
+func (r *transitiveRules) inherit(i conf.Import, config conf.Conf, imports []conf.Import) {
+	roots := []string{i.Package}
+	for _, im := range imports {
+		roots = append(roots, im.Package)
+	}
+	from := path.Join(i.Package, path.Base(config.ConfFile()))
+	for _, e := range config.Excludes {
+		if !matchesAny(roots, e) {
+			logrus.Warnf("Exclude '%s' in %s is outside of what '%s' imports: ignoring it", e, from, i.Package)
+			continue
+		}
+		logrus.Debugf("Excluding '%s' (from %s)", e, from)
+		r.excludes = append(r.excludes, e)
+	}
+	for _, p := range config.Packages {
+		if !matchesAny(roots, p) {
+			logrus.Warnf("package=%s in %s is outside of what '%s' imports: ignoring it", p, from, i.Package)
+			continue
+		}
+		logrus.Debugf("Keeping package '%s' (from %s)", p, from)
+		r.packages = append(r.packages, p)
+	}
+}
+
+// report tells which overrides applied and which packages were ignored, and warns about overrides that matched nothing
+func (r *transitiveRules) report() {
+	pkgs := []string{}
+	for pkg := range r.overrides {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		if replaced, ok := r.applied[pkg]; ok {
+			logrus.Infof("Override applied: '%s' is %s instead of %s", pkg, overrideString(r.overrides[pkg]), strings.Join(replaced, ", "))
+		} else {
+			logrus.Warnf("Override of '%s' in %s did not apply: nothing in the dependency graph imports it", pkg, r.confFile)
+		}
+	}
+	pkgs = []string{}
+	for pkg := range r.ignored {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		logrus.Infof("Ignored transitive import '%s' (from %s)", pkg, strings.Join(r.ignored[pkg], ", "))
+	}
+}
+
+func overrideString(o conf.Import) string {
+	switch {
+	case o.Version == "":
+		return "from " + o.Repo
+	case o.Repo == "":
+		return o.Version
+	}
+	return versionAndRepo(o)
+}
+
+// appendMissing appends the values that are not in list yet
+func appendMissing(list []string, values ...string) []string {
+	have := map[string]bool{}
+	for _, v := range list {
+		have[v] = true
+	}
+	for _, v := range values {
+		if !have[v] {
+			have[v] = true
+			list = append(list, v)
+		}
+	}
+	return list
+}
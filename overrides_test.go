@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestTransitiveRules(t *testing.T) {
+	assert := require.New(t)
+
+	rules := newTransitiveRules(&conf.Conf{
+		Overrides: []conf.Import{
+			{Package: "example.com/b", Version: "v1.2.0"},
+			{Package: "example.com/c", Repo: "https://example.com/fork/c.git"},
+			{Package: "example.com/unused", Version: "v1"},
+		},
+		IgnoreTransitive: []string{"example.com/d", "golang.org/x/*"},
+	})
+
+	root := rules.apply([]conf.Import{{Package: "example.com/d", Version: "v1"}}, false)
+	assert.Len(root, 1, "conf imports are never ignored")
+
+	imports := rules.apply([]conf.Import{
+		{Package: "example.com/b", Version: "v1.0.0", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/c", Version: "v0.1.0", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/d/sub", Version: "v1", From: "example.com/a/vendor.conf"},
+		{Package: "golang.org/x/net", Version: "abc", From: "example.com/a/vendor.conf"},
+		{Package: "example.com/e", Version: "v2", From: "example.com/a/vendor.conf"},
+	}, true)
+	assert.Equal([]conf.Import{
+		{Package: "example.com/b", Version: "v1.2.0", From: "example.com/a/vendor.conf", Reason: "override in "},
+		{Package: "example.com/c", Version: "v0.1.0", Repo: "https://example.com/fork/c.git", From: "example.com/a/vendor.conf", Reason: "override in "},
+		{Package: "example.com/e", Version: "v2", From: "example.com/a/vendor.conf"},
+	}, imports)
+	assert.Equal([]string{"v1.0.0 from example.com/a/vendor.conf"}, rules.applied["example.com/b"])
+	assert.NotContains(rules.applied, "example.com/unused")
+	assert.Len(rules.ignored, 2)
+}
+
+func TestInherit(t *testing.T) {
+	assert := require.New(t)
+
+	config, err := conf.ParseBytes("vendor.conf", []byte(`example.com/a
+example.com/b v1.0.0
+example.com/c v2.0.0
+-example.com/b/docs
+-example.com/other/examples
+package=example.com/c/plugin
+package=example.com/other/plugin
+`))
+	assert.Nil(err)
+
+	rules := newTransitiveRules(&conf.Conf{})
+	rules.inherit(conf.Import{Package: "example.com/a"}, *config, config.Imports)
+	assert.Equal([]string{"example.com/b/docs"}, rules.excludes)
+	assert.Equal([]string{"example.com/c/plugin"}, rules.packages)
+
+	assert.Equal([]string{"a", "b", "c"}, appendMissing([]string{"a", "b"}, "b", "c", "a"))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+)
+
+var pruneCommand = cli.Command{
+	Name:   "prune",
+	Usage:  "Re-run the cleanup on the existing vendor dir without fetching or copying anything",
+	Action: prune,
+}
+
+func prune(c *cli.Context) error {
+	dir, _, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	targetDir := c.GlobalString("target")
+	if _, err := os.Stat(targetDir); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	withLockedImports(dir, trashConf)
+
+	if err := cleanup(false, dir, targetDir, trashConf); err != nil {
+		logrus.Error(err)
+		return err
+	}
+
+	report, err := checkDeps(dir, targetDir, trashConf)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	for _, m := range report.Missing {
+		logrus.Warnf("Package '%s' is needed but missing from '%s' (%s)", m.Package, targetDir, m.Reason)
+	}
+	if len(report.Missing) > 0 {
+		return fmt.Errorf("%d needed package(s) are missing: run trash to refetch them", len(report.Missing))
+	}
+	return nil
+}
+
+// withLockedImports replaces the conf imports with what trash.lock says is actually vendored:
+// that includes the transitive imports, which are not in the conf. The excludes and package= entries
+// the transitive imports' conf files add are taken from trash.lock too, and so are the packages --flatten lifted.
+func withLockedImports(dir string, trashConf *conf.Conf) {
+	lock, err := conf.Parse("trash.lock")
+	if err != nil {
+		logrus.Debugf("Could not read trash.lock in '%s', using the conf imports: %s", dir, err)
+		return
+	}
+	for _, i := range trashConf.Imports {
+		if _, ok := lock.Get(i.Package); !ok {
+			lock.Imports = append(lock.Imports, i)
+		}
+	}
+	trashConf.Imports = lock.Imports
+	trashConf.Dedupe()
+	trashConf.Excludes = appendMissing(trashConf.Excludes, lock.Excludes...)
+	trashConf.Packages = appendMissing(trashConf.Packages, lock.Packages...)
+	trashConf.Flattened = lock.Flattened
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestPrune(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "trash-prune")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	write := func(file, content string) {
+		assert.Nil(os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755))
+		assert.Nil(ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	}
+	write("vendor.conf", "example.com/proj\nexample.com/a v1.0.0\n")
+	write("main.go", "package main\n\nimport _ \"example.com/a\"\n")
+	write("vendor/example.com/a/a.go", "package a\n\nimport _ \"example.com/lifted\"\nimport _ \"example.com/b\"\n")
+	write("vendor/example.com/a/unused/u.go", "package unused")
+	write("vendor/example.com/b/b.go", "package b")
+	write("vendor/example.com/lifted/l.go", "package lifted")
+	write("vendor/example.com/gone/g.go", "package gone")
+	write("trash.lock", `package: example.com/proj
+import:
+- package: example.com/a
+  version: v1.0.0
+  commit: aaaa
+- package: example.com/b
+  version: v2.0.0
+  from: example.com/a/vendor.conf
+flattened:
+- package: example.com/lifted
+  from: example.com/a/vendor
+- package: example.com/gone
+  from: example.com/a/vendor
+  conflicts:
+  - example.com/b/vendor
+`)
+	assert.Nil(os.Chdir(dir))
+
+	trashConf, err := conf.Parse("vendor.conf")
+	assert.Nil(err)
+	withLockedImports(dir, trashConf)
+	assert.Len(trashConf.Imports, 2, "the transitive imports are in trash.lock")
+	assert.Nil(cleanup(false, dir, "vendor", trashConf))
+
+	for file, exists := range map[string]bool{
+		"vendor/example.com/a/a.go":      true,
+		"vendor/example.com/a/unused":    false,
+		"vendor/example.com/b/b.go":      true,
+		"vendor/example.com/lifted/l.go": true,
+		"vendor/example.com/gone":        false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, file))
+		assert.Equal(exists, err == nil, file)
+	}
+	lock, err := conf.Parse("trash.lock")
+	assert.Nil(err)
+	assert.Len(lock.Imports, 2)
+	assert.Equal([]conf.Flattened{{Package: "example.com/lifted", From: "example.com/a/vendor"}}, lock.Flattened,
+		"prune keeps the record of what --flatten lifted, as long as it's vendored")
+}
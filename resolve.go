@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/semver"
+	"github.com/rancher/trash/util"
+)
+
+// needsResolving tells if the version is not a git ref but has to be resolved to a commit, which trash.lock then keeps until -u
+func needsResolving(version string) bool {
+	return semver.IsConstraint(version) || conf.IsDatePin(version) || isRef(version)
+}
+
+// isRef tells if the version is a full ref name, like "refs/pull/123/head", or a refspec, like "refs/changes/34/1234/2:refs/review"
+func isRef(version string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(version, "+"), "refs/")
+}
+
+// refspec is the (forced) refspec fetching a ref version from the remote, and the local ref it's fetched to:
+// the destination of the refspec, or a ref under refs/trash/<remote>/ for ref names
+func refspec(remote, version string) (spec, src, local string) {
+	src = strings.TrimPrefix(version, "+")
+	if colon := strings.Index(src, ":"); colon >= 0 {
+		src, local = src[:colon], src[colon+1:]
+	} else {
+		local = "refs/trash/" + remote + "/" + strings.TrimPrefix(src, "refs/")
+	}
+	return "+" + src + ":" + local, src, local
+}
+
+// parseLock reads trash.lock in dir: it's empty if there's none
+func parseLock(dir string) *conf.Conf {
+	lock, err := conf.Parse(path.Join(dir, "trash.lock"))
+	if err != nil {
+		logrus.Debugf("Could not read trash.lock in '%s': %s", dir, err)
+		return &conf.Conf{}
+	}
+	return lock
+}
+
+// lockedRevisions sets what the imports resolved to on the last run, from trash.lock, unless the versions changed since.
+// Versions that need resolving keep their commit until they are updated, and so do all the imports not updated in update mode.
+// It returns the packages it set.
+func lockedRevisions(update bool, lock, trashConf *conf.Conf) map[string]bool {
+	locked := map[string]bool{}
+	for k, i := range trashConf.Imports {
+		if i.Commit != "" || (update && i.Update) || (!update && !needsResolving(i.Version)) {
+			continue
+		}
+		if l, ok := lock.Get(i.Package); ok && l.Version == i.Version && l.Repo == i.Repo && l.Commit != "" {
+			trashConf.Imports[k].Ref, trashConf.Imports[k].Commit = l.Ref, l.Commit
+			locked[i.Package] = true
+		}
+	}
+	return locked
+}
+
+// checkLocked compares what the import is checked out at with trash.lock: tags must not have moved,
+// and the locked commit must still be in the history of branches. It warns, or fails in strict mode.
+func checkLocked(lock *conf.Conf, i conf.Import) error {
+	l, ok := lock.Get(i.Package)
+	if !ok || l.Commit == "" || l.Version != i.Version || l.Repo != i.Repo || isRef(i.Version) {
+		return nil
+	}
+	remote := remoteName(i.Repo)
+	problem := ""
+	switch {
+	case semver.IsConstraint(i.Version):
+		// checked out at the locked commit: it's the tag it resolved to that could have moved
+		if sha := revParse(l.Ref); sha != "" && sha != l.Commit {
+			problem = fmt.Sprintf("tag '%s' moved: it was at %s in trash.lock and is now at %s", l.Ref, l.Commit, sha)
+		}
+	case conf.IsDatePin(i.Version):
+		branch, _, err := conf.ParseDatePin(i.Version)
+		if err == nil && branch == "" {
+			branch, err = defaultBranch(remote)
+		}
+		if err == nil && !isAncestor(l.Commit, remote+"/"+branch) {
+			problem = fmt.Sprintf("branch '%s' was force-pushed: %s in trash.lock is not in its history anymore", branch, l.Commit)
+		}
+	case isDefaultBranch(i.Version) || isBranch(remote, i.Version):
+		if !isAncestor(l.Commit, i.Commit) {
+			problem = fmt.Sprintf("branch '%s' was force-pushed: %s in trash.lock is not in its history anymore, it's now at %s", i.Version, l.Commit, i.Commit)
+		}
+	case strings.HasPrefix(i.Commit, i.Version):
+		// SHA pins don't move
+	case i.Commit != l.Commit:
+		problem = fmt.Sprintf("tag '%s' moved: it was at %s in trash.lock and is now at %s", i.Version, l.Commit, i.Commit)
+	}
+	if problem == "" {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("'%s': %s", i.Package, problem)
+	}
+	logrus.Warnf("'%s': %s (use --strict to fail)", i.Package, problem)
+	return nil
+}
+
+// revParse is the commit rev resolves to in the current dir's repo, or "" if it's not there
+func revParse(rev string) string {
+	bytes, err := exec.Command("git", "rev-parse", "--verify", "-q", rev+"^{commit}").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bytes))
+}
+
+// resolve sets the ref and commit the version of the import resolves to, using the cached repo
+func resolve(trashDir string, i *conf.Import) error {
+	repoDir := path.Join(trashDir, "src", i.Package)
+	if err := os.Chdir(repoDir); err != nil {
+		return err
+	}
+	if err := fetch(*i); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case isRef(i.Version):
+		err = resolveRef(i)
+	case conf.IsDatePin(i.Version):
+		err = resolveDatePin(i)
+	default:
+		err = resolveConstraint(i)
+	}
+	if err != nil {
+		return err
+	}
+	if i.Ref != "" && !isRef(i.Version) {
+		logrus.Infof("Resolved '%s' %s to %s, commit %s", i.Package, i.Version, i.Ref, i.Commit)
+	} else {
+		logrus.Infof("Resolved '%s' %s to commit %s", i.Package, i.Version, i.Commit)
+	}
+	return nil
+}
+
+// resolveConstraint picks the highest tag in the range
+func resolveConstraint(i *conf.Import) error {
+	c, err := semver.ParseConstraint(i.Version)
+	if err != nil {
+		return err
+	}
+	tags := []string{}
+	for l := range util.CmdOutLines(exec.Command("git", "tag", "-l")) {
+		tags = append(tags, strings.TrimSpace(l))
+	}
+	best := c.Best(tags)
+	if best == nil {
+		return fmt.Errorf("no tag of '%s' matches '%s'", i.Package, i.Version)
+	}
+	bytes, err := exec.Command("git", "rev-parse", "--verify", best.Original+"^{commit}").Output()
+	if err != nil {
+		return fmt.Errorf("`git rev-parse --verify %s^{commit}` failed for '%s': %s", best.Original, i.Package, err)
+	}
+	i.Ref, i.Commit = best.Original, strings.TrimSpace(string(bytes))
+	return nil
+}
+
+// resolveDatePin picks the newest commit of the branch's history (following first parents) that is no later than the date
+func resolveDatePin(i *conf.Import) error {
+	branch, before, err := conf.ParseDatePin(i.Version)
+	if err != nil {
+		return err
+	}
+	remote := remoteName(i.Repo)
+	if branch == "" {
+		if branch, err = defaultBranch(remote); err != nil {
+			return err
+		}
+	}
+	if !isBranch(remote, branch) {
+		return fmt.Errorf("'%s' has no branch '%s' for version '%s'", i.Package, branch, i.Version)
+	}
+	args := []string{"rev-list", "-1", "--first-parent", "--before=" + before.Format(time.RFC3339), remote + "/" + branch}
+	bytes, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return fmt.Errorf("`git %s` failed for '%s': %s", strings.Join(args, " "), i.Package, err)
+	}
+	if i.Commit = strings.TrimSpace(string(bytes)); i.Commit == "" {
+		return fmt.Errorf("'%s' has no commit on branch '%s' before %s", i.Package, branch, before.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// resolveRef takes the commit of the ref, which fetch has fetched
+func resolveRef(i *conf.Import) error {
+	_, src, local := refspec(remoteName(i.Repo), i.Version)
+	bytes, err := exec.Command("git", "rev-parse", "--verify", local+"^{commit}").Output()
+	if err != nil {
+		return fmt.Errorf("could not resolve '%s' for '%s': `git rev-parse --verify %s^{commit}` failed: %s", i.Version, i.Package, local, err)
+	}
+	i.Ref, i.Commit = src, strings.TrimSpace(string(bytes))
+	return nil
+}
+
+// commitDate is the date of a commit in the current dir's repo, or "" if it's not there
+func commitDate(rev string) string {
+	bytes, err := exec.Command("git", "log", "-1", "--date=short", "--format=%cd", rev).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bytes))
+}
+
+// displayVersion is the version, with what it resolved to if it's not a git ref
+func displayVersion(i conf.Import) string {
+	switch {
+	case i.Ref != "" && !isRef(i.Version):
+		return fmt.Sprintf("%s (%s)", i.Version, i.Ref)
+	case i.Commit != "" && !strings.HasPrefix(i.Commit, i.Version):
+		return fmt.Sprintf("%s (%s)", i.Version, abbrev(i.Commit))
+	}
+	return i.Version
+}
+
+func abbrev(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
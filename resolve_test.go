@@ -0,0 +1,172 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+)
+
+func TestRefspec(t *testing.T) {
+	assert := require.New(t)
+
+	assert.True(isRef("refs/pull/123/head"))
+	assert.True(isRef("+refs/changes/34/1234/2:refs/review/1234"))
+	assert.False(isRef("v1.2.0"))
+	assert.False(isRef("master"))
+
+	spec, src, local := refspec("origin", "refs/pull/123/head")
+	assert.Equal("+refs/pull/123/head:refs/trash/origin/pull/123/head", spec)
+	assert.Equal("refs/pull/123/head", src)
+	assert.Equal("refs/trash/origin/pull/123/head", local)
+
+	spec, src, local = refspec("origin", "+refs/changes/34/1234/2:refs/review/1234")
+	assert.Equal("+refs/changes/34/1234/2:refs/review/1234", spec)
+	assert.Equal("refs/changes/34/1234/2", src)
+	assert.Equal("refs/review/1234", local)
+}
+
+func TestDisplayVersion(t *testing.T) {
+	assert := require.New(t)
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	assert.Equal("v1.2.0", displayVersion(conf.Import{Version: "v1.2.0"}))
+	assert.Equal("v1.2.0 (0123456789ab)", displayVersion(conf.Import{Version: "v1.2.0", Commit: sha}))
+	assert.Equal("0123456", displayVersion(conf.Import{Version: "0123456", Commit: sha}))
+	assert.Equal("^1.2 (v1.4.0)", displayVersion(conf.Import{Version: "^1.2", Ref: "v1.4.0", Commit: sha}))
+	assert.Equal("@2019-03-01 (0123456789ab)", displayVersion(conf.Import{Version: "@2019-03-01", Commit: sha}))
+	assert.Equal("refs/pull/1/head (0123456789ab)", displayVersion(conf.Import{Version: "refs/pull/1/head", Ref: "refs/pull/1/head", Commit: sha}))
+}
+
+func TestCheckLocked(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir("", "trash-locked")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	assert.Nil(os.Chdir(dir))
+	defer func(s bool) { strict = s }(strict)
+	git := func(args ...string) string {
+		bytes, err := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...).CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+		return strings.TrimSpace(string(bytes))
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "a")
+	a := git("rev-parse", "HEAD")
+	git("checkout", "-q", "-b", "rewritten")
+	git("commit", "-q", "--allow-empty", "-m", "c")
+	c := git("rev-parse", "HEAD")
+	git("checkout", "-q", "--detach", a)
+	git("commit", "-q", "--allow-empty", "-m", "b")
+	b := git("rev-parse", "HEAD")
+	git("update-ref", "refs/remotes/origin/develop", b)
+	git("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/develop")
+	git("tag", "v1.0.0", b)
+
+	lock := func(i conf.Import) *conf.Conf {
+		l := &conf.Conf{Imports: []conf.Import{i}}
+		l.Dedupe()
+		return l
+	}
+	pkg := "example.com/a"
+	strict = true
+	for _, test := range []struct {
+		locked, i conf.Import
+		problem   string
+	}{
+		{
+			conf.Import{Package: pkg, Version: "v1.0.0", Commit: a},
+			conf.Import{Package: pkg, Version: "v1.0.0", Commit: b},
+			"'example.com/a': tag 'v1.0.0' moved: it was at " + a + " in trash.lock and is now at " + b,
+		},
+		{
+			conf.Import{Package: pkg, Version: "^1.0.0", Ref: "v1.0.0", Commit: a},
+			conf.Import{Package: pkg, Version: "^1.0.0", Ref: "v1.0.0", Commit: a},
+			"'example.com/a': tag 'v1.0.0' moved: it was at " + a + " in trash.lock and is now at " + b,
+		},
+		{
+			conf.Import{Package: pkg, Version: "develop", Commit: c},
+			conf.Import{Package: pkg, Version: "develop", Commit: b},
+			"'example.com/a': branch 'develop' was force-pushed: " + c + " in trash.lock is not in its history anymore, it's now at " + b,
+		},
+		{
+			conf.Import{Package: pkg, Version: "HEAD", Commit: c},
+			conf.Import{Package: pkg, Version: "HEAD", Commit: b},
+			"'example.com/a': branch 'HEAD' was force-pushed: " + c + " in trash.lock is not in its history anymore, it's now at " + b,
+		},
+		{
+			conf.Import{Package: pkg, Version: "develop@2019-03-01", Commit: c},
+			conf.Import{Package: pkg, Version: "develop@2019-03-01", Commit: c},
+			"'example.com/a': branch 'develop' was force-pushed: " + c + " in trash.lock is not in its history anymore",
+		},
+		{conf.Import{Package: pkg, Version: "develop", Commit: a}, conf.Import{Package: pkg, Version: "develop", Commit: b}, ""},
+		{conf.Import{Package: pkg, Version: "v1.0.0", Commit: b}, conf.Import{Package: pkg, Version: "v1.0.0", Commit: b}, ""},
+		{conf.Import{Package: pkg, Version: a[:7], Commit: a}, conf.Import{Package: pkg, Version: a[:7], Commit: a}, ""},
+		{conf.Import{Package: pkg, Version: "v0.9.0", Commit: a}, conf.Import{Package: pkg, Version: "v1.0.0", Commit: b}, ""},
+	} {
+		err := checkLocked(lock(test.locked), test.i)
+		if test.problem == "" {
+			assert.Nil(err, test.i.Version)
+			continue
+		}
+		assert.NotNil(err, test.i.Version)
+		assert.Equal(test.problem, err.Error())
+	}
+
+	strict = false
+	assert.Nil(checkLocked(lock(conf.Import{Package: pkg, Version: "v1.0.0", Commit: a}), conf.Import{Package: pkg, Version: "v1.0.0", Commit: b}), "only a warning")
+}
+
+func TestResolveImportsFetchesLocked(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir("", "trash-locked")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	defer func(s bool) { strict = s }(strict)
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...)
+		cmd.Dir = dir
+		bytes, err := cmd.CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+		return strings.TrimSpace(string(bytes))
+	}
+	up, trashDir, dir := filepath.Join(root, "up"), filepath.Join(root, "cache"), filepath.Join(root, "proj")
+	for _, d := range []string{up, filepath.Join(trashDir, "src", "example.com"), dir} {
+		assert.Nil(os.MkdirAll(d, 0755))
+	}
+	git(up, "init", "-q")
+	git(up, "commit", "-q", "--allow-empty", "-m", "a")
+	git(up, "tag", "v1.0.0")
+	a := git(up, "rev-parse", "HEAD")
+	git(root, "clone", "-q", up, filepath.Join(trashDir, "src", "example.com", "a"))
+	git(up, "commit", "-q", "--allow-empty", "-m", "b")
+	git(up, "tag", "-f", "v1.0.0")
+	b := git(up, "rev-parse", "HEAD")
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "trash.lock"), []byte("import:\n- package: example.com/a\n  version: v1.0.0\n  commit: "+a+"\n"), 0644))
+
+	strict = false
+	trashConf := &conf.Conf{Imports: []conf.Import{{Package: "example.com/a", Version: "v1.0.0"}}}
+	trashConf.Dedupe()
+	assert.Nil(resolveImports(false, trashDir, dir, trashConf, false), "moved tags are warnings")
+	assert.Equal(b, trashConf.Imports[0].Commit, "the tag was fetched even without --strict")
+
+	strict = true
+	trashConf = &conf.Conf{Imports: []conf.Import{{Package: "example.com/a", Version: "v1.0.0"}}}
+	trashConf.Dedupe()
+	assert.NotNil(resolveImports(false, trashDir, dir, trashConf, false))
+}
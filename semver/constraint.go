@@ -0,0 +1,131 @@
+package semver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Constraint is a version range like "^1.4", "~v0.8.7" or ">=1.2,<2", optionally for the tags with a prefix, like "api/^1.2"
+type Constraint struct {
+	Prefix   string // tag prefix, with its trailing '/', for the modules of monorepos
+	bounds   []bound
+	original string
+}
+
+type bound struct {
+	op string // one of "=", ">", ">=", "<", "<="
+	v  *Version
+}
+
+// IsConstraint tells if a version is a range (as opposed to a tag, branch or commit): ranges start with an operator
+func IsConstraint(s string) bool {
+	s = s[strings.LastIndex(s, "/")+1:]
+	return s != "" && strings.ContainsAny(s[:1], "^~<>=")
+}
+
+// ParseConstraint parses comma separated ranges that must all match. Caret and tilde ranges work like npm's.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: s}
+	if slash := strings.LastIndex(s, "/"); slash >= 0 {
+		c.Prefix, s = s[:slash+1], s[slash+1:]
+	}
+	for _, r := range strings.Split(s, ",") {
+		bounds, err := parseRange(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint '%s': %s", c.original, err)
+		}
+		c.bounds = append(c.bounds, bounds...)
+	}
+	return c, nil
+}
+
+func parseRange(r string) ([]bound, error) {
+	version := strings.TrimLeft(r, "^~<>=")
+	op := r[:len(r)-len(version)]
+	v, err := Parse(version)
+	if err != nil {
+		return nil, err
+	}
+	// how many of major, minor and patch are given: "^0.8" and "^0.8.7" are different ranges
+	parts := len(strings.Split(strings.SplitN(strings.SplitN(version, "-", 2)[0], "+", 2)[0], "."))
+	switch op {
+	case "^":
+		upper := &Version{Major: v.Major + 1}
+		switch {
+		case v.Major == 0 && (v.Minor > 0 || parts == 2):
+			upper = &Version{Minor: v.Minor + 1}
+		case v.Major == 0 && parts == 3:
+			upper = &Version{Patch: v.Patch + 1}
+		}
+		return []bound{{">=", v}, {"<", upper}}, nil
+	case "~":
+		upper := &Version{Major: v.Major, Minor: v.Minor + 1}
+		if parts == 1 {
+			upper = &Version{Major: v.Major + 1}
+		}
+		return []bound{{">=", v}, {"<", upper}}, nil
+	case "=", ">", ">=", "<", "<=":
+		return []bound{{op, v}}, nil
+	case "":
+		return nil, fmt.Errorf("missing operator in '%s'", r)
+	}
+	return nil, fmt.Errorf("unknown operator '%s'", op)
+}
+
+// Match tells if the version is in the range. Pre-releases only match if one of the bounds is a pre-release.
+func (c *Constraint) Match(v *Version) bool {
+	pre := false
+	for _, b := range c.bounds {
+		pre = pre || b.v.PreRelease()
+		cmp := v.Compare(b.v)
+		ok := false
+		switch b.op {
+		case "=":
+			ok = cmp == 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return pre || !v.PreRelease()
+}
+
+// Best returns the highest tag that matches, or nil if none does
+func (c *Constraint) Best(tags []string) *Version {
+	var best *Version
+	for _, v := range ParsePrefixedTags(tags, c.Prefix) {
+		if c.Match(v) {
+			best = v
+		}
+	}
+	return best
+}
+
+func (c *Constraint) String() string {
+	return c.original
+}
+
+// ParsePrefixedTags is ParseTags for the tags that start with the prefix, like "api/v1.2.3" for "api/"
+func ParsePrefixedTags(tags []string, prefix string) []*Version {
+	r := []*Version{}
+	for _, t := range tags {
+		if !strings.HasPrefix(t, prefix) {
+			continue
+		}
+		if v, err := Parse(strings.TrimPrefix(t, prefix)); err == nil {
+			v.Original = t
+			r = append(r, v)
+		}
+	}
+	sort.Sort(byVersion(r))
+	return r
+}
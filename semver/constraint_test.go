@@ -0,0 +1,53 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraint(t *testing.T) {
+	assert := require.New(t)
+
+	for s, cases := range map[string]map[string]bool{
+		"^1.4":      {"1.4.0": true, "1.9.2": true, "1.3.9": false, "2.0.0": false, "1.5.0-rc.1": false},
+		"^0.8.7":    {"0.8.7": true, "0.8.9": true, "0.9.0": false},
+		"^0.0.3":    {"0.0.3": true, "0.0.4": false},
+		"~v0.8.7":   {"0.8.7": true, "0.8.10": true, "0.9.0": false, "0.8.6": false},
+		"~1":        {"1.0.0": true, "1.9.0": true, "2.0.0": false},
+		">=1.2,<2":  {"1.2.0": true, "1.99.0": true, "2.0.0": false, "1.1.0": false},
+		"=1.2.3":    {"1.2.3": true, "1.2.4": false},
+		"^2.0.0-rc": {"2.0.0-rc.1": true, "2.0.0": true, "3.0.0": false},
+	} {
+		c, err := ParseConstraint(s)
+		assert.Nil(err, s)
+		for version, match := range cases {
+			v, _ := Parse(version)
+			assert.Equal(match, c.Match(v), "%s matches %s", s, version)
+		}
+	}
+
+	for _, s := range []string{"1.2", "^", "^1.x", "!1.2"} {
+		_, err := ParseConstraint(s)
+		assert.NotNil(err, s)
+	}
+}
+
+func TestBest(t *testing.T) {
+	assert := require.New(t)
+
+	tags := []string{"v1.2.0", "v1.4.1", "v1.5.0", "v2.0.0", "api/v1.3.0", "api/v1.2.9", "api/v2.0.0"}
+	c, _ := ParseConstraint("^1.4")
+	assert.Equal("v1.5.0", c.Best(tags).Original)
+	c, _ = ParseConstraint("api/^1.2")
+	assert.Equal("api/", c.Prefix)
+	assert.Equal("api/v1.3.0", c.Best(tags).Original)
+	c, _ = ParseConstraint("~1.3")
+	assert.Nil(c.Best(tags))
+
+	assert.True(IsConstraint("^1.4"))
+	assert.True(IsConstraint("api/>=1.2"))
+	assert.False(IsConstraint("v1.4.0"))
+	assert.False(IsConstraint("master"))
+	assert.False(IsConstraint(""))
+}
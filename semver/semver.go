@@ -0,0 +1,146 @@
+// Package semver parses and compares the semantic versions of git tags.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string // pre-release, after the '-'
+	Original            string // the tag, as it was parsed
+}
+
+// Parse parses a tag like "v1.2.3", "1.2", "v1" or "1.2.3-rc.1+build". The "v" and missing minor or patch are optional.
+func Parse(s string) (*Version, error) {
+	v := &Version{Original: s}
+	s = strings.TrimPrefix(s, "v")
+	if plus := strings.Index(s, "+"); plus >= 0 {
+		s = s[:plus]
+	}
+	if dash := strings.Index(s, "-"); dash >= 0 {
+		s, v.Pre = s[:dash], s[dash+1:]
+		if v.Pre == "" {
+			return nil, fmt.Errorf("invalid semantic version '%s': empty pre-release", v.Original)
+		}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid semantic version '%s': too many parts", v.Original)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for k, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid semantic version '%s'", v.Original)
+		}
+		*numbers[k] = n
+	}
+	return v, nil
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+func (v *Version) PreRelease() bool {
+	return v.Pre != ""
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or higher than o
+func (v *Version) Compare(o *Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre compares dot separated pre-release identifiers: numeric ones numerically, others lexically
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for k := 0; k < len(as) && k < len(bs); k++ {
+		an, aErr := strconv.Atoi(as[k])
+		bn, bErr := strconv.Atoi(bs[k])
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil && bErr != nil:
+			return -1
+		case aErr != nil && bErr == nil:
+			return 1
+		case as[k] < bs[k]:
+			return -1
+		case as[k] > bs[k]:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// Diff tells what kind of update going from v to o is: "major", "minor", "patch", "pre-release" or "" if o is not higher
+func (v *Version) Diff(o *Version) string {
+	switch {
+	case v.Compare(o) >= 0:
+		return ""
+	case o.Major != v.Major:
+		return "major"
+	case o.Minor != v.Minor:
+		return "minor"
+	case o.Patch != v.Patch:
+		return "patch"
+	}
+	return "pre-release"
+}
+
+// ParseTags parses the tags that are semantic versions, ignoring the others, and sorts them lowest first
+func ParseTags(tags []string) []*Version {
+	return ParsePrefixedTags(tags, "")
+}
+
+// Latest returns the highest version, skipping pre-releases unless pre is set. It returns nil if there's none.
+func Latest(versions []*Version, pre bool) *Version {
+	var latest *Version
+	for _, v := range versions {
+		if v.PreRelease() && !pre {
+			continue
+		}
+		if latest == nil || v.Compare(latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+type byVersion []*Version
+
+func (v byVersion) Len() int           { return len(v) }
+func (v byVersion) Less(i, j int) bool { return v[i].Compare(v[j]) < 0 }
+func (v byVersion) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
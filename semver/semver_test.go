@@ -0,0 +1,52 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	assert := require.New(t)
+
+	v, err := Parse("v1.2.3-rc.1+build.5")
+	assert.Nil(err)
+	assert.Equal(Version{1, 2, 3, "rc.1", "v1.2.3-rc.1+build.5"}, *v)
+
+	v, err = Parse("2")
+	assert.Nil(err)
+	assert.Equal("2.0.0", v.String())
+
+	for _, s := range []string{"master", "a1b2c3d", "v1.2.3.4", "v1.x", "1.2.3-", ""} {
+		_, err := Parse(s)
+		assert.NotNil(err, s)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	assert := require.New(t)
+
+	ordered := []string{"0.9.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "v1.0.1", "1.1", "2.0.0"}
+	for k := 1; k < len(ordered); k++ {
+		a, _ := Parse(ordered[k-1])
+		b, _ := Parse(ordered[k])
+		assert.Equal(-1, a.Compare(b), "%s < %s", ordered[k-1], ordered[k])
+		assert.Equal(1, b.Compare(a), "%s > %s", ordered[k], ordered[k-1])
+	}
+}
+
+func TestDiffAndLatest(t *testing.T) {
+	assert := require.New(t)
+
+	versions := ParseTags([]string{"v1.2.0", "v1.10.0", "v2.0.0-rc.1", "latest", "v1.2.1"})
+	assert.Len(versions, 4)
+	assert.Equal("v1.2.0", versions[0].Original)
+
+	current, _ := Parse("v1.2.0")
+	assert.Equal("v1.10.0", Latest(versions, false).Original)
+	assert.Equal("v2.0.0-rc.1", Latest(versions, true).Original)
+	assert.Equal("minor", current.Diff(Latest(versions, false)))
+	assert.Equal("major", current.Diff(Latest(versions, true)))
+	assert.Equal("patch", current.Diff(versions[1]))
+	assert.Equal("", current.Diff(current))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+)
+
+var initCommand = cli.Command{
+	Name:  "init",
+	Usage: "Create the conf file from the project's imports",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "package",
+			Usage: "The project's root package (guessed from GOPATH if not set)",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the conf file instead of writing it",
+		},
+	},
+	Action: initConf,
+}
+
+var tidyCommand = cli.Command{
+	Name:  "tidy",
+	Usage: "Add missing imports to the conf file and drop the unused ones",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the changes instead of writing them",
+		},
+	},
+	Action: tidy,
+}
+
+func initConf(c *cli.Context) error {
+	dir, trashDir, err := prepareDir(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	confFile := c.GlobalString("file")
+	if existing, err := findConfFile(confFile); err == nil {
+		err := fmt.Errorf("'%s' already exists: use `trash tidy` to update it", existing)
+		logrus.Error(err)
+		return err
+	}
+
+	trashConf := &conf.Conf{Package: c.String("package")}
+	if trashConf.Package == "" {
+		trashConf.Package = guessRootPackage(dir)
+	}
+	added, _, err := updateTrash(trashDir, dir, c.GlobalString("target"), trashConf, c.GlobalBool("insecure"))
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	trashConf.Imports = added
+	trashConf.Dedupe()
+
+	if c.Bool("dry-run") {
+		printChanges(added, nil)
+		return nil
+	}
+	if err := trashConf.Dump(confFile); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	logrus.Infof("Created '%s' with %d import(s): run `trash` to vendor them", confFile, len(added))
+	return nil
+}
+
+func tidy(c *cli.Context) error {
+	dir, trashDir, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	added, removed, err := updateTrash(trashDir, dir, c.GlobalString("target"), trashConf, c.GlobalBool("insecure"))
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	if c.Bool("dry-run") || len(added)+len(removed) == 0 {
+		printChanges(added, removed)
+		return nil
+	}
+
+	for _, i := range removed {
+		if err := trashConf.Remove(i.Package); err != nil {
+			logrus.Error(err)
+			return err
+		}
+	}
+	for _, i := range added {
+		if err := trashConf.Add(i); err != nil {
+			logrus.Error(err)
+			return err
+		}
+	}
+	printChanges(added, removed)
+	logrus.Infof("Updated '%s': run `trash` to vendor the changes", trashConf.ConfFile())
+	return nil
+}
+
+func printChanges(added, removed []conf.Import) {
+	if len(added)+len(removed) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+	for _, i := range removed {
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("- %s\t%s\t%s", i.Package, i.Version, i.Repo)))
+	}
+	for _, i := range added {
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("+ %s\t%s\t%s", i.Package, i.Version, i.Repo)))
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/urfave/cli"
 
-	"github.com/Masterminds/glide/godep"
 	"github.com/rancher/trash/conf"
 	"github.com/rancher/trash/util"
 	"gopkg.in/yaml.v2"
@@ -27,6 +26,11 @@ import (
 var Version string = "v0.3.0-dev"
 
 func main() {
+	newApp().Run(os.Args)
+}
+
+// newApp is the trash command line: its global flags, the vendoring action and the commands
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.Version = Version
 	app.Author = "@imikushin, @ibuildthecloud"
@@ -78,14 +82,59 @@ func main() {
 			Name:  "include-vendor",
 			Usage: "whether to include vendor when running trash -k",
 		},
+		cli.StringFlag{
+			Name:  "conflicts",
+			Value: rootWins,
+			Usage: "How to resolve transitive imports of different versions of a package: " + strings.Join(conflictPolicies, ", "),
+		},
+		cli.BoolFlag{
+			Name:  "flatten",
+			Usage: "Lift the packages of the dependencies' vendor dirs into the top-level vendor dir, instead of dropping them",
+		},
+		cli.BoolFlag{
+			Name:  "no-test",
+			Usage: "Leave out the test-only imports (test=true, like glide's testImport)",
+		},
+		cli.BoolFlag{
+			Name:  "strict",
+			Usage: "Fail instead of warning when a tag moved or a branch was force-pushed since trash.lock",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print what would be fetched, copied and deleted without changing anything",
+		},
 	}
 	app.Action = runWrapper
-
-	app.Run(os.Args)
+	app.Commands = []cli.Command{
+		lintCommand,
+		addCommand,
+		removeCommand,
+		setCommand,
+		initCommand,
+		tidyCommand,
+		migrateCommand,
+		checkCommand,
+		pruneCommand,
+		whyCommand,
+		graphCommand,
+		outdatedCommand,
+		changelogCommand,
+	}
+	return app
 }
 
 var gopath string
 
+// strict makes moved tags and force-pushed branches (compared with trash.lock) errors
+var strict bool
+
+// noTest leaves the test-only imports out of the vendor dir and trash.lock
+var noTest bool
+
+// skipDirs are the project's dirs listPackages skips like the target dir: the target dir itself, while dry runs and
+// migrations populate a scratch dir in its place
+var skipDirs []string
+
 func runWrapper(ctx *cli.Context) error {
 	if err := run(ctx); err != nil {
 		logrus.Error(err)
@@ -106,7 +155,16 @@ func run(c *cli.Context) error {
 	insecure := c.Bool("insecure")
 	trashDir := c.String("cache")
 	gopath = c.String("gopath")
+	strict = c.Bool("strict")
+	noTest = c.Bool("no-test")
+	flatten = c.Bool("flatten")
+	if conflictPolicy = c.String("conflicts"); !validPolicy(conflictPolicy) {
+		return fmt.Errorf("unknown --conflicts policy '%s': use one of %s", conflictPolicy, strings.Join(conflictPolicies, ", "))
+	}
 	includeVendor := c.Bool("include-vendor")
+	if flatten && includeVendor {
+		return fmt.Errorf("--flatten and --include-vendor don't go together: flattening removes the nested vendor dirs")
+	}
 
 	update := false
 	updateVendor := c.StringSlice("update")
@@ -128,13 +186,9 @@ func run(c *cli.Context) error {
 	}
 	logrus.Debugf("dir: '%s'", dir)
 
-	for _, confFile = range []string{confFile, "trash.conf", "vndr.cfg", "vendor.manifest", "trash.yml", "glide.yaml", "glide.yml", "trash.yaml"} {
-		if _, err = os.Stat(confFile); err == nil {
-			break
-		}
-	}
+	confFile, err = findConfFile(confFile)
 	if err != nil {
-		if os.IsNotExist(err) && update {
+		if os.IsNotExist(err) && update && !c.Bool("dry-run") {
 			confFile = c.String("file")
 			logrus.Warnf("Trash! '%s' not found, creating a new one!", confFile)
 			if _, err = os.Create(confFile); err != nil {
@@ -152,90 +206,66 @@ func run(c *cli.Context) error {
 	}
 
 	if update {
-		imports := []conf.Import{}
-		for _, imp := range updateVendor {
-			for k, existingImp := range trashConf.ImportMap {
-				if strings.Contains(k, imp) {
-					existingImp.Update = true
-					imports = append(imports, existingImp)
+		// keep all the imports, so that trash.lock still has them: only the marked ones get updated
+		for k, i := range trashConf.Imports {
+			for _, imp := range updateVendor {
+				if strings.Contains(i.Package, imp) {
+					trashConf.Imports[k].Update = true
 				}
 			}
 		}
-		trashConf.Imports = imports
 	}
-	alreadyImported := map[string]bool{}
-	extraImports, err := updateTransitiveVendor(keep, update, trashDir, dir, targetDir, trashConf, insecure, alreadyImported)
-	if err != nil {
-		return err
+	if c.Bool("dry-run") {
+		return dryRun(keep, update, includeVendor, insecure, trashDir, dir, targetDir, trashConf)
 	}
+	return trash(keep, update, includeVendor, insecure, trashDir, dir, targetDir, trashConf)
+}
 
-	// clean duplicate imports
-	importMap := map[string]conf.Import{}
-	for _, i := range extraImports {
-		importMap[i.Package] = i
+// trash vendors the conf imports and cleans up. In update mode only the imports marked for update are vendored and cleaned.
+func trash(keep, update, includeVendor, insecure bool, trashDir, dir, targetDir string, trashConf *conf.Conf) error {
+	if err := populate(keep, update, includeVendor, insecure, trashDir, dir, targetDir, trashConf); err != nil {
+		return err
 	}
-	extraImports = []conf.Import{}
-	for _, i := range importMap {
-		extraImports = append(extraImports, i)
+	if keep {
+		return nil
 	}
+	return writeLock(dir, targetDir, trashConf)
+}
 
-	var filteredExtraImports []conf.Import
-	for _, extraImport := range extraImports {
-		packageAlreadyImported := false
-		for _, packageImport := range trashConf.Imports {
-			if packageImport.Package == extraImport.Package {
-				packageAlreadyImported = true
-				break
-			}
-		}
-		if !packageAlreadyImported {
-			filteredExtraImports = append(filteredExtraImports, extraImport)
-		}
+// populate is what trash does to the target dir: it vendors the conf imports (and their transitive imports) and cleans up,
+// leaving trash.lock alone. Dry runs and migrations populate a scratch dir with it.
+func populate(keep, update, includeVendor, insecure bool, trashDir, dir, targetDir string, trashConf *conf.Conf) error {
+	leaveOutTestImports(update, trashConf)
+	if err := addTransitiveImports(update, trashDir, dir, trashConf, insecure); err != nil {
+		return err
 	}
-	trashConf.Imports = append(trashConf.Imports, filteredExtraImports...)
 
 	if err := vendor(keep, update, trashDir, dir, targetDir, trashConf, insecure); err != nil {
 		return err
 	}
 
-	if !update {
+	if flatten {
 		vendorDir := path.Join(dir, targetDir)
-		for _, packageImport := range trashConf.Imports {
-			if !packageImport.Staging {
-				continue
-			}
-
-			packageLocation := path.Dir(packageImport.Package)
-			baseDir := path.Join(trashDir, "src", packageImport.Package, "staging/src", packageLocation)
-
-			files, err := ioutil.ReadDir(baseDir)
-			if err != nil {
-				return err
-			}
-			for _, f := range files {
-				repoDir := path.Join(baseDir, f.Name())
-				target := path.Join(vendorDir, packageLocation)
-				os.MkdirAll(target, 0755)
-				if bytes, err := exec.Command("cp", "-a", repoDir, target).CombinedOutput(); err != nil {
-					return fmt.Errorf("`cp -a %s %s` failed:\n%s", repoDir, target, bytes)
-				}
-			}
+		flattened, err := flattenVendor(vendorDir, trashConf)
+		if err != nil {
+			return err
+		}
+		if update {
+			flattened = keepFlattened(flattened, parseLock(dir).Flattened, vendorDir)
 		}
+		trashConf.Flattened = flattened
 	}
 
 	if keep {
 		if !includeVendor {
-			wd, err := os.Getwd()
-			if err != nil {
-				return err
-			}
-			root := filepath.Join(wd, "vendor")
+			root := filepath.Join(dir, targetDir)
 			return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 				if err != nil {
 					return filepath.SkipDir
 				}
 				if info.IsDir() && info.Name() == "vendor" && path != root {
 					logrus.Infof("Removing %s", path)
+					recordDeletion(path, "nested vendor dir")
 					os.RemoveAll(path)
 					return filepath.SkipDir
 				}
@@ -244,21 +274,101 @@ func run(c *cli.Context) error {
 		}
 		return nil
 	}
-	return cleanup(update, dir, targetDir, trashConf)
+	return cleanVendor(update, dir, targetDir, trashConf)
+}
+
+// confFiles are the conf file names trash looks for, in order, if --file is not found
+var confFiles = []string{"vendor.conf", "trash.conf", "vndr.cfg", "vendor.manifest", "trash.yml", "glide.yaml", "glide.yml", "trash.yaml"}
+
+func findConfFile(confFile string) (string, error) {
+	var err error
+	for _, f := range append([]string{confFile}, confFiles...) {
+		if _, err = os.Stat(f); err == nil {
+			return f, nil
+		}
+	}
+	return confFile, err
+}
+
+// prepare does the common setup for commands: changes to --directory and parses the conf file.
+// It returns the absolute paths of the project dir and the cache dir.
+func prepare(c *cli.Context) (dir, trashDir string, trashConf *conf.Conf, err error) {
+	if dir, trashDir, err = prepareDir(c); err != nil {
+		return
+	}
+	confFile, err := findConfFile(c.GlobalString("file"))
+	if err != nil {
+		return
+	}
+	logrus.Debugf("Reading file: '%s'", confFile)
+	trashConf, err = conf.Parse(confFile)
+	return
 }
 
-func updateTransitiveVendor(keep, update bool, trashDir, dir, targetDir string, trashConf *conf.Conf, insecure bool, alreadyImported map[string]bool) ([]conf.Import, error) {
+// prepareDir is prepare without the conf file
+func prepareDir(c *cli.Context) (dir, trashDir string, err error) {
+	if c.GlobalBool("debug") {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	gopath = c.GlobalString("gopath")
+	strict = c.GlobalBool("strict")
+	noTest = c.GlobalBool("no-test")
+	flatten = c.GlobalBool("flatten")
+	if conflictPolicy = c.GlobalString("conflicts"); !validPolicy(conflictPolicy) {
+		err = fmt.Errorf("unknown --conflicts policy '%s': use one of %s", conflictPolicy, strings.Join(conflictPolicies, ", "))
+		return
+	}
+
+	if trashDir, err = filepath.Abs(c.GlobalString("cache")); err != nil {
+		return
+	}
+	if err = os.Chdir(c.GlobalString("directory")); err != nil {
+		return
+	}
+	if dir, err = os.Getwd(); err != nil {
+		return
+	}
+	logrus.Debugf("dir: '%s'", dir)
+	return
+}
+
+// addTransitiveImports adds the imports of the transitive imports to the conf imports, applying the conf's overrides
+// and ignore-transitive patterns. The transitive imports' manifests are read from the cache at the commits they resolve to:
+// nothing is checked out or vendored.
+func addTransitiveImports(update bool, trashDir, dir string, trashConf *conf.Conf, insecure bool) error {
+	rules := newTransitiveRules(trashConf)
+	trashConf.Imports = rules.apply(trashConf.Imports, false)
+	trashConf.Dedupe()
+
+	alreadyImported := map[string]bool{}
+	extraImports, err := updateTransitiveVendor(update, trashDir, dir, trashConf, insecure, alreadyImported, rules)
+	if err != nil {
+		return err
+	}
+	rules.report()
+	trashConf.Excludes = appendMissing(trashConf.Excludes, rules.excludes...)
+	trashConf.Packages = appendMissing(trashConf.Packages, rules.packages...)
+
+	if extraImports, err = resolveConflicts(conflictPolicy, trashConf, extraImports); err != nil {
+		return err
+	}
+	trashConf.Imports = append(trashConf.Imports, extraImports...)
+	trashConf.Dedupe()
+	return nil
+}
+
+func updateTransitiveVendor(update bool, trashDir, dir string, trashConf *conf.Conf, insecure bool, alreadyImported map[string]bool, rules *transitiveRules) ([]conf.Import, error) {
 	extraImports := []conf.Import{}
-	// we don't need to vendor files first if none of the imports are transitive
-	updateVendor := false
+	// we don't need to resolve the imports first if none of them are transitive
+	resolveFirst := false
 	for _, packageImport := range trashConf.Imports {
 		if packageImport.Transitive {
-			updateVendor = true
+			resolveFirst = true
 			break
 		}
 	}
-	if updateVendor {
-		if err := vendor(keep, update, trashDir, dir, targetDir, trashConf, insecure); err != nil {
+	if resolveFirst {
+		if err := resolveImports(update, trashDir, dir, trashConf, insecure); err != nil {
 			return extraImports, err
 		}
 	}
@@ -273,23 +383,34 @@ func updateTransitiveVendor(keep, update bool, trashDir, dir, targetDir string,
 				continue
 			}
 			repoDir := path.Join(trashDir, "src", packageImport.Package)
-			transitiveDependencies, err := godep.Parse(repoDir)
+			manifest, manifestImports, err := readManifest(repoDir, packageImport.Commit)
 			if err != nil {
 				return extraImports, err
 			}
-			for _, transitiveDependency := range transitiveDependencies {
-				extraImports = append(extraImports, conf.Import{
-					Package: transitiveDependency.Name,
-					Version: transitiveDependency.Reference,
-					Repo:    transitiveDependency.Repository,
-				})
+			for k := range manifestImports {
+				manifestImports[k].Update = packageImport.Update
+				manifestImports[k].From = path.Join(packageImport.Package, manifest)
 			}
-			if len(transitiveDependencies) == 0 {
-				config, err := parseTransitiveVendor(repoDir)
-				if err != nil {
-					return extraImports, err
+			extraImports = append(extraImports, rules.apply(manifestImports, true)...)
+			config, err := parseTransitiveVendor(repoDir, packageImport.Commit)
+			if err != nil {
+				return extraImports, err
+			}
+			if !packageImport.IgnoreExcludes {
+				imports := manifestImports
+				if manifest == "" {
+					imports = config.Imports
+				}
+				rules.inherit(packageImport, config, imports)
+			}
+			if manifest == "" {
+				for k := range config.Imports {
+					config.Imports[k].Update = packageImport.Update
+					config.Imports[k].From = path.Join(packageImport.Package, filepath.Base(config.ConfFile()))
 				}
-				if imports, err := updateTransitiveVendor(keep, update, trashDir, dir, targetDir, &config, insecure, alreadyImported); err != nil {
+				config.Imports = rules.apply(config.Imports, true)
+				config.Dedupe()
+				if imports, err := updateTransitiveVendor(update, trashDir, dir, &config, insecure, alreadyImported, rules); err != nil {
 					return extraImports, err
 				} else {
 					extraImports = append(extraImports, imports...)
@@ -301,26 +422,32 @@ func updateTransitiveVendor(keep, update bool, trashDir, dir, targetDir string,
 	return extraImports, nil
 }
 
-func parseTransitiveVendor(repoDir string) (conf.Conf, error) {
-	configFile := ""
-	for _, f := range []string{"vendor.conf", "trash.conf", "vndr.cfg", "vendor.manifest", "trash.yml", "glide.yaml", "glide.yml", "trash.yaml"} {
-		if _, err := os.Stat(filepath.Join(repoDir, f)); err == nil {
-			configFile = filepath.Join(repoDir, f)
-			break
-		}
-	}
-	if configFile == "" {
-		return conf.Conf{}, nil
-	}
-	trashConf, err := conf.Parse(configFile)
+// parseTransitiveVendor parses the conf file the repo has at the commit
+func parseTransitiveVendor(repoDir, commit string) (conf.Conf, error) {
+	files, err := filesAt(repoDir, commit, confFiles)
 	if err != nil {
 		return conf.Conf{}, err
 	}
-	return *trashConf, nil
+	for _, f := range confFiles {
+		if !files[f] {
+			continue
+		}
+		data, err := showFile(repoDir, commit, f)
+		if err != nil {
+			return conf.Conf{}, err
+		}
+		trashConf, err := conf.ParseBytes(f, data)
+		if err != nil {
+			return conf.Conf{}, err
+		}
+		return *trashConf, nil
+	}
+	return conf.Conf{}, nil
 }
 
-func updateTrash(trashDir, dir, targetDir, trashFile string, trashConf *conf.Conf, insecure bool) error {
-	// TODO collect imports, create `trashConf *conf.Trash`
+// updateTrash crawls the project's imports, fetching the packages that are not in the conf, and works out the conf changes:
+// the repos that need to be added (pinned to their latest tag or commit) and the imports nothing uses anymore.
+func updateTrash(trashDir, dir, targetDir string, trashConf *conf.Conf, insecure bool) (added, removed []conf.Import, err error) {
 	rootPackage := trashConf.Package
 	if rootPackage == "" {
 		rootPackage = guessRootPackage(dir)
@@ -328,23 +455,35 @@ func updateTrash(trashDir, dir, targetDir, trashFile string, trashConf *conf.Con
 
 	os.MkdirAll(filepath.Join(trashDir, "src"), 0755)
 	os.Setenv("GOPATH", trashDir)
+	defer os.Chdir(dir)
 
 	libRoot := filepath.Join(trashDir, "src")
-	importsLen := 0
+	transitive := false
+	for _, i := range trashConf.Imports {
+		transitive = transitive || i.Transitive
+	}
 
+	crawled := map[string]bool{}
+	checkedOut := map[string]bool{}
 	os.Chdir(dir)
 	imports := collectImports(rootPackage, libRoot, targetDir)
-	for len(imports) > importsLen {
-		importsLen = len(imports)
+	for len(imports) > len(crawled) {
 		for pkg := range imports {
-			i, ok := trashConf.Get(pkg)
-			if !ok {
-				i = conf.Import{Package: pkg}
+			if crawled[pkg] {
+				continue
 			}
-			i.Version = "master"
+			crawled[pkg] = true
 			if pkg == rootPackage || strings.HasPrefix(pkg, rootPackage+"/") {
 				continue
 			}
+			i, ok := coveringImport(trashConf, pkg)
+			if !ok {
+				i = conf.Import{Package: pkg, Version: "HEAD"}
+			}
+			if checkedOut[i.Package] {
+				continue
+			}
+			checkedOut[i.Package] = true
 			prepareCache(trashDir, i, insecure)
 			checkout(trashDir, i)
 		}
@@ -352,33 +491,81 @@ func updateTrash(trashDir, dir, targetDir, trashFile string, trashConf *conf.Con
 		imports = collectImports(rootPackage, libRoot, targetDir)
 	}
 
-	trashConf.Package = rootPackage // Overwrite possibly non existent root package name
-	trashConf.Imports = nil         // Drop any old imports to include only new ones
+	used := map[string]bool{}
+	roots := map[string]bool{}
 	for pkg := range imports {
 		if pkg == rootPackage || strings.HasPrefix(pkg, rootPackage+"/") {
 			continue
 		}
-		pkg, err := topLevel(pkg, libRoot)
-		if err != nil {
-			return err
+		if i, ok := coveringImport(trashConf, pkg); ok {
+			used[i.Package] = true
+			continue
 		}
-		i, ok := trashConf.Get(pkg) // Get uses importMap for meta fields, which was preserved above
-		if !ok {
-			i = conf.Import{Package: pkg}
+		if _, err := os.Stat(filepath.Join(dir, targetDir, pkg)); transitive && err == nil {
+			logrus.Infof("Package '%s' is not in the conf but vendored: assuming it comes from a transitive import", pkg)
+			continue
 		}
-		i.Version, err = getLatestVersion(libRoot, pkg)
+		root, err := topLevel(pkg, libRoot)
 		if err != nil {
-			return err
+			return nil, nil, fmt.Errorf("could not find the repo root of package '%s': %s", pkg, err)
+		}
+		roots[root] = true
+	}
+	for _, pkg := range trashConf.Packages {
+		if i, ok := coveringImport(trashConf, pkg); ok {
+			used[i.Package] = true
 		}
-		os.Chdir(dir)
-		trashConf.Imports = append(trashConf.Imports, i)
 	}
-	trashConf.Dedupe()
 
-	os.Chdir(dir)
-	trashConf.Dump(trashFile)
+	for _, i := range trashConf.Imports {
+		if !used[i.Package] {
+			removed = append(removed, i)
+		}
+	}
+	for root := range roots {
+		i := conf.Import{Package: root}
+		if i.Version, err = getLatestVersion(libRoot, root); err != nil {
+			return nil, nil, err
+		}
+		added = append(added, i)
+	}
+	sort.Sort(conf.Imports(added))
+	return added, removed, nil
+}
 
-	return nil
+// leaveOutTestImports drops the test-only imports from the conf with --no-test, along with what they import transitively.
+// In update mode, only the ones marked for update are: the others stay vendored as they are.
+func leaveOutTestImports(update bool, trashConf *conf.Conf) {
+	if !noTest {
+		return
+	}
+	imports := []conf.Import{}
+	for _, i := range trashConf.Imports {
+		if i.Test && (!update || i.Update) {
+			logrus.Infof("Leaving out test-only import '%s'", i.Package)
+			continue
+		}
+		imports = append(imports, i)
+	}
+	trashConf.Imports = imports
+	trashConf.Dedupe()
+}
+
+// coveringImport finds the conf import the package belongs to: the import of its repo, or else the import mapping it
+func coveringImport(trashConf *conf.Conf, pkg string) (conf.Import, bool) {
+	for p := pkg; p != "." && p != "/"; p = path.Dir(p) {
+		if i, ok := trashConf.Get(p); ok {
+			return i, true
+		}
+	}
+	for _, i := range trashConf.Imports {
+		for _, m := range i.PathMappings() {
+			if pkg == m.Package || strings.HasPrefix(pkg, m.Package+"/") {
+				return i, true
+			}
+		}
+	}
+	return conf.Import{}, false
 }
 
 func topLevel(pkg, libRoot string) (string, error) {
@@ -390,14 +577,21 @@ func topLevel(pkg, libRoot string) (string, error) {
 		return "", err
 	}
 	s := strings.TrimSpace(string(bytes))
+	if !strings.HasPrefix(s, libRoot+"/") {
+		return "", fmt.Errorf("repo dir '%s' is outside of '%s'", s, libRoot)
+	}
 	return s[len(libRoot)+1:], nil
 }
 
+// getLatestVersion returns the tag of the checked out commit if it has one, or its SHA
 func getLatestVersion(libRoot, pkg string) (string, error) {
 	if err := os.Chdir(filepath.Join(libRoot, pkg)); err != nil {
 		return "", err
 	}
-	bytes, err := exec.Command("git", "describe", "--tags", "--always").Output()
+	if bytes, err := exec.Command("git", "describe", "--tags", "--exact-match").Output(); err == nil {
+		return strings.TrimSpace(string(bytes)), nil
+	}
+	bytes, err := exec.Command("git", "rev-parse", "HEAD").Output()
 	if err != nil {
 		return "", err
 	}
@@ -409,21 +603,8 @@ func vendor(keep, update bool, trashDir, dir, targetDir string, trashConf *conf.
 	logrus.WithFields(logrus.Fields{"keep": keep, "dir": dir, "trashConf": trashConf}).Debug("vendor")
 	defer os.Chdir(dir)
 
-	for _, i := range trashConf.Imports {
-		if i.Version == "" {
-			return fmt.Errorf("version not specified for package '%s'", i.Package)
-		}
-	}
-
-	os.MkdirAll(trashDir, 0755)
-	os.Setenv("GOPATH", trashDir)
-
-	for _, i := range trashConf.Imports {
-		if update && !i.Update {
-			continue
-		}
-		prepareCache(trashDir, i, insecure)
-		checkout(trashDir, i)
+	if err := checkoutImports(update, trashDir, dir, trashConf, insecure); err != nil {
+		return err
 	}
 
 	vendorDir := path.Join(dir, targetDir)
@@ -436,6 +617,13 @@ func vendor(keep, update bool, trashDir, dir, targetDir string, trashConf *conf.
 				}
 			}
 		}
+		for _, i := range trashConf.Imports {
+			if i.Update {
+				if err := copyMappings(vendorDir, trashConf, i); err != nil {
+					return err
+				}
+			}
+		}
 		logrus.Info("Moving deps... Done")
 	} else {
 		os.RemoveAll(vendorDir)
@@ -447,6 +635,11 @@ func vendor(keep, update bool, trashDir, dir, targetDir string, trashConf *conf.
 				return err
 			}
 		}
+		for _, i := range trashConf.Imports {
+			if err := copyMappings(vendorDir, trashConf, i); err != nil {
+				return err
+			}
+		}
 		logrus.Info("Copying deps... Done")
 	}
 	if !keep {
@@ -462,6 +655,7 @@ func vendor(keep, update bool, trashDir, dir, targetDir string, trashConf *conf.
 			}
 			if _, d := filepath.Split(path); d == ".git" {
 				logrus.Infof("removing '%s", path)
+				recordDeletion(path, ".git dir")
 				return os.RemoveAll(path)
 			}
 			return nil
@@ -474,6 +668,83 @@ func vendor(keep, update bool, trashDir, dir, targetDir string, trashConf *conf.
 	return nil
 }
 
+// checkoutImports checks out the conf imports in the cache (only the ones marked for update in update mode)
+func checkoutImports(update bool, trashDir, dir string, trashConf *conf.Conf, insecure bool) error {
+	defer os.Chdir(dir)
+
+	if err := resolveImports(update, trashDir, dir, trashConf, insecure); err != nil {
+		return err
+	}
+	for _, i := range trashConf.Imports {
+		if update && !i.Update {
+			continue
+		}
+		checkout(trashDir, i)
+	}
+	return nil
+}
+
+// resolveImports sets the commits the conf imports are at in the cache, fetching what's missing, without checking them out
+// (only the ones marked for update in update mode). Imports that have a commit already keep it.
+func resolveImports(update bool, trashDir, dir string, trashConf *conf.Conf, insecure bool) error {
+	defer os.Chdir(dir)
+
+	for _, i := range trashConf.Imports {
+		if i.Version == "" {
+			return fmt.Errorf("version not specified for package '%s'", i.Package)
+		}
+	}
+
+	os.MkdirAll(trashDir, 0755)
+	os.Setenv("GOPATH", trashDir)
+
+	lock := parseLock(dir)
+	locked := lockedRevisions(update, lock, trashConf)
+	for k, i := range trashConf.Imports {
+		if update && !i.Update {
+			continue
+		}
+		prepareCache(trashDir, i, insecure)
+		if i.Commit != "" && !locked[i.Package] {
+			continue
+		}
+		if _, ok := lock.Get(i.Package); ok {
+			// compare with what's upstream, not with what's cached: tags that moved are only seen once fetched
+			if err := fetch(i); err != nil {
+				if strict {
+					return err
+				}
+				logrus.Warnf("Could not fetch '%s' to compare it with trash.lock: checking the cached repo (use --strict to fail)", i.Package)
+			}
+		}
+		switch {
+		case locked[i.Package]:
+			if err := checkLocked(lock, i); err != nil {
+				return err
+			}
+			continue
+		case needsResolving(i.Version):
+			if err := resolve(trashDir, &trashConf.Imports[k]); err != nil {
+				return err
+			}
+			continue
+		}
+		repoDir := path.Join(trashDir, "src", i.Package)
+		if err := os.Chdir(repoDir); err != nil {
+			return err
+		}
+		commit, err := pinnedCommit(i)
+		if err != nil {
+			return err
+		}
+		trashConf.Imports[k].Commit = commit
+		if err := checkLocked(lock, trashConf.Imports[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func prepareCache(trashDir string, i conf.Import, insecure bool) {
 	logrus.WithFields(logrus.Fields{"trashDir": trashDir, "i": i}).Debug("entering prepareCache")
 	os.Chdir(trashDir)
@@ -494,37 +765,100 @@ func isBranch(remote, version string) bool {
 	return false
 }
 
+// checkout checks out the import in the cache at its commit, resolving its version first if it has none
 func checkout(trashDir string, i conf.Import) {
 	logrus.WithFields(logrus.Fields{"trashDir": trashDir, "i": i}).Debug("entering checkout")
 	repoDir := path.Join(trashDir, "src", i.Package)
 	if err := os.Chdir(repoDir); err != nil {
 		logrus.Fatalf("Could not change to dir '%s'", repoDir)
 	}
-	logrus.Infof("Checking out '%s', commit: '%s'", i.Package, i.Version)
-	version := i.Version
-	if i.Version == "master" || isBranch(remoteName(i.Repo), i.Version) {
-		version = remoteName(i.Repo) + "/" + i.Version
+	switch {
+	case i.Commit != "":
+	case needsResolving(i.Version):
+		if err := resolve(trashDir, &i); err != nil {
+			logrus.Fatal(err)
+		}
+	default:
+		commit, err := pinnedCommit(i)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		i.Commit = commit
+	}
+	logrus.Infof("Checking out '%s', commit: '%s'", i.Package, displayVersion(i))
+	if bytes, err := exec.Command("git", "checkout", "-f", "--detach", i.Commit).CombinedOutput(); err != nil {
+		logrus.Debugf("Error running `git checkout -f --detach %s`:\n%s", i.Commit, bytes)
 		if err := fetch(i); err != nil {
 			logrus.WithFields(logrus.Fields{"i": i}).Fatalf("fetch failed")
 		}
+		logrus.Debugf("Retrying!: `git checkout -f --detach %s`", i.Commit)
+		if bytes, err := exec.Command("git", "checkout", "-f", "--detach", i.Commit).CombinedOutput(); err != nil {
+			logrus.Fatalf("`git checkout -f --detach %s` failed:\n%s", i.Commit, bytes)
+		}
 	}
-	if bytes, err := exec.Command("git", "checkout", "-f", "--detach", version).CombinedOutput(); err != nil {
-		logrus.Debugf("Error running `git checkout -f --detach %s`:\n%s", version, bytes)
-		if i.Version == "master" {
-			logrus.Warn("Failed to checkout 'master' branch: checking out the latest commit git can find")
-			bytes, err := exec.Command("git", "log", "--all", "--pretty=oneline", "--abbrev-commit", "-1").Output()
-			if err != nil {
-				logrus.Fatalf("Failed to get latest commit with `git log --all --pretty=oneline --abbrev-commit -1`: %s", err)
-			}
-			version = strings.Fields(strings.TrimSpace(string(bytes)))[0]
-		} else if err := fetch(i); err != nil {
-			logrus.WithFields(logrus.Fields{"i": i}).Fatalf("fetch failed")
+}
+
+// pinnedCommit is the commit the version of the import is at in the current dir's repo: branches (and the default branch)
+// are fetched first, and the other versions if the cache doesn't have them
+func pinnedCommit(i conf.Import) (string, error) {
+	remote := remoteName(i.Repo)
+	version := i.Version
+	switch {
+	case isDefaultBranch(i.Version):
+		if err := fetch(i); err != nil {
+			return "", fmt.Errorf("could not fetch '%s': %s", i.Package, err)
+		}
+		branch, err := defaultBranch(remote)
+		if err != nil {
+			return "", err
+		}
+		logrus.Infof("Default branch of '%s' is '%s'", i.Package, branch)
+		version = remote + "/" + branch
+	case isBranch(remote, i.Version):
+		version = remote + "/" + i.Version
+		if err := fetch(i); err != nil {
+			return "", fmt.Errorf("could not fetch '%s': %s", i.Package, err)
+		}
+	}
+	if commit := revParse(version); commit != "" {
+		return commit, nil
+	}
+	logrus.Debugf("'%s' has no '%s' in the cache", i.Package, version)
+	if err := fetch(i); err != nil {
+		return "", fmt.Errorf("could not fetch '%s': %s", i.Package, err)
+	}
+	if isBranch(remote, version) {
+		version = remote + "/" + version
+	}
+	if commit := revParse(version); commit != "" {
+		return commit, nil
+	}
+	if i.Version == "master" {
+		if branch, err := defaultBranch(remote); err == nil && branch != "master" {
+			return "", fmt.Errorf("'%s' has no 'master' branch: its default branch is '%s' (use version 'HEAD' to follow the default branch)", i.Package, branch)
+		}
+	}
+	return "", fmt.Errorf("'%s' has no version '%s'", i.Package, i.Version)
+}
+
+// isDefaultBranch tells if the version is a keyword for the remote's default branch
+func isDefaultBranch(version string) bool {
+	return version == "HEAD" || version == "default"
+}
+
+// defaultBranch is the branch the remote's HEAD points to. If the cache doesn't know it, it asks the remote.
+func defaultBranch(remote string) (string, error) {
+	ref := "refs/remotes/" + remote + "/HEAD"
+	bytes, err := exec.Command("git", "symbolic-ref", "--short", ref).Output()
+	if err != nil {
+		if out, err := exec.Command("git", "remote", "set-head", remote, "--auto").CombinedOutput(); err != nil {
+			return "", fmt.Errorf("could not find the default branch of remote '%s': `git remote set-head %s --auto` failed:\n%s", remote, remote, out)
 		}
-		logrus.Debugf("Retrying!: `git checkout -f --detach %s`", version)
-		if bytes, err := exec.Command("git", "checkout", "-f", "--detach", version).CombinedOutput(); err != nil {
-			logrus.Fatalf("`git checkout -f --detach %s` failed:\n%s", version, bytes)
+		if bytes, err = exec.Command("git", "symbolic-ref", "--short", ref).Output(); err != nil {
+			return "", fmt.Errorf("could not find the default branch of remote '%s': `git symbolic-ref --short %s` failed: %s", remote, ref, err)
 		}
 	}
+	return strings.TrimPrefix(strings.TrimSpace(string(bytes)), remote+"/"), nil
 }
 
 func cpy(vendorDir, trashDir string, i conf.Import) error {
@@ -537,10 +871,82 @@ func cpy(vendorDir, trashDir string, i conf.Import) error {
 	return nil
 }
 
+// copyMappings copies the dirs the import maps from its vendored repo to the packages they're mapped to.
+// What the packages had is replaced, unless they're parents of the import's own package (like with staging=true):
+// then only the entries the dir has are. Mapping to the packages of another conf import is an error.
+func copyMappings(vendorDir string, trashConf *conf.Conf, i conf.Import) error {
+	for _, m := range i.PathMappings() {
+		src := path.Join(vendorDir, i.Package, m.Dir)
+		entries, err := ioutil.ReadDir(src)
+		if err != nil {
+			logrus.Warnf("'%s' has no dir '%s' to map to '%s' (in %s): %s", i.Package, m.Dir, m.Package, i.Version, err)
+			continue
+		}
+		logrus.Infof("Mapping '%s' of '%s' to '%s'", m.Dir, i.Package, m.Package)
+		parent := i.Package == m.Package || strings.HasPrefix(i.Package, m.Package+"/")
+		targets := []string{m.Package}
+		if parent {
+			targets = []string{}
+			for _, e := range entries {
+				targets = append(targets, path.Join(m.Package, e.Name()))
+			}
+		}
+		for _, t := range targets {
+			if o, ok := mappingConflict(trashConf, i, t); ok {
+				return fmt.Errorf("'%s' maps '%s' to '%s', which '%s' vendors too (in %s): drop one of them", i.Package, m.Dir, t, o, trashConf.ConfFile())
+			}
+		}
+
+		target := path.Join(vendorDir, m.Package)
+		if !parent {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := os.RemoveAll(path.Join(target, e.Name())); err != nil {
+				return err
+			}
+			if bytes, err := exec.Command("cp", "-a", path.Join(src, e.Name()), target).CombinedOutput(); err != nil {
+				return fmt.Errorf("`cp -a %s %s` failed:\n%s", path.Join(src, e.Name()), target, bytes)
+			}
+		}
+	}
+	return nil
+}
+
+// mappingConflict finds another conf import that has packages at the path or under it, itself or through its mappings
+func mappingConflict(trashConf *conf.Conf, i conf.Import, p string) (string, bool) {
+	overlaps := func(pkg string) bool {
+		return p == pkg || strings.HasPrefix(p, pkg+"/") || strings.HasPrefix(pkg, p+"/")
+	}
+	for _, o := range trashConf.Imports {
+		if o.Package == i.Package {
+			continue
+		}
+		if overlaps(o.Package) {
+			return o.Package, true
+		}
+		for _, m := range o.PathMappings() {
+			if m.Package != o.Package && !strings.HasPrefix(o.Package, m.Package+"/") && overlaps(m.Package) {
+				return o.Package + " (mapping '" + m.Dir + "')", true
+			}
+		}
+	}
+	return "", false
+}
+
 func mv(vendorDir, trashDir string, i conf.Import) error {
 	repoDir := path.Join(trashDir, "src", i.Package)
 	target := path.Join(vendorDir, i.Package)
 	os.RemoveAll(target)
+	if recorder != nil {
+		// dry runs leave the cache as it is
+		return cpy(vendorDir, trashDir, i)
+	}
 	os.MkdirAll(target, 0755)
 	logrus.Infof("Moving %s to %s", repoDir, filepath.Dir(target))
 	if bytes, err := exec.Command("mv", repoDir, filepath.Dir(target)).CombinedOutput(); err != nil {
@@ -651,6 +1057,14 @@ func fetch(i conf.Import) error {
 		logrus.Errorf("`git fetch -f -t %s` failed:\n%s", remote, bytes)
 		return err
 	}
+	if isRef(i.Version) {
+		// refs like pull requests' are not fetched by default
+		spec, _, _ := refspec(remote, i.Version)
+		if bytes, err := exec.Command("git", "fetch", "-f", remote, spec).CombinedOutput(); err != nil {
+			logrus.Errorf("`git fetch -f %s %s` failed:\n%s", remote, spec, bytes)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -667,7 +1081,8 @@ func parentPackages(root, p string) util.Packages {
 	return r
 }
 
-func listImports(rootPackage, libRoot, pkg string) <-chan util.Packages {
+// listImports lists the imports of pkg (and pkg itself). The import edges are recorded in graph, if it's not nil.
+func listImports(rootPackage, libRoot, pkg string, graph *util.Graph) <-chan util.Packages {
 	pkgPath := "."
 	if pkg != rootPackage {
 		if strings.HasPrefix(pkg, rootPackage+"/") {
@@ -710,6 +1125,7 @@ func listImports(rootPackage, libRoot, pkg string) <-chan util.Packages {
 					if imp == rootPackage || strings.HasPrefix(imp, rootPackage+"/") {
 						continue
 					}
+					graph.Add(pkg, imp, util.Import)
 					sch <- imp
 					logrus.Debugf("listImports, sch <- '%s'", v.Path.Value[1:len(v.Path.Value)-1])
 				}
@@ -749,7 +1165,9 @@ func listImports(rootPackage, libRoot, pkg string) <-chan util.Packages {
 								if line = strings.TrimSpace(line); strings.HasPrefix(line, "#include \"") {
 									if includePath := filepath.Dir(line[10 : len(line)-1]); includePath != "." {
 										if _, err := os.Stat(filepath.Join(pkgPath, includePath)); !os.IsNotExist(err) {
-											sch <- filepath.Clean(filepath.Join(pkg, includePath))
+											includePkg := filepath.Clean(filepath.Join(pkg, includePath))
+											graph.Add(pkg, includePkg, util.CgoInclude)
+											sch <- includePkg
 										}
 									}
 								}
@@ -784,6 +1202,11 @@ func listPackages(rootPackage, targetDir string) util.Packages {
 		if !info.IsDir() {
 			return nil
 		}
+		for _, d := range skipDirs {
+			if path == d {
+				return filepath.SkipDir
+			}
+		}
 		if path == targetDir ||
 			strings.HasSuffix(path, targetDir+"/") ||
 			path != "." && strings.HasPrefix(path[strings.LastIndex(path, "/")+1:], ".") {
@@ -809,6 +1232,11 @@ func listPackages(rootPackage, targetDir string) util.Packages {
 }
 
 func collectImports(rootPackage, libRoot, targetDir string) util.Packages {
+	return collectImportGraph(rootPackage, libRoot, targetDir, nil)
+}
+
+// collectImportGraph collects all the packages reachable from the project's packages, recording the import edges in graph if it's not nil
+func collectImportGraph(rootPackage, libRoot, targetDir string, graph *util.Graph) util.Packages {
 	logrus.Infof("Collecting packages in '%s'", rootPackage)
 
 	imports := util.Packages{}
@@ -818,7 +1246,7 @@ func collectImports(rootPackage, libRoot, targetDir string) util.Packages {
 	for len(packages) > 0 {
 		cs := []<-chan util.Packages{}
 		for p := range packages {
-			cs = append(cs, listImports(rootPackage, libRoot, p))
+			cs = append(cs, listImports(rootPackage, libRoot, p, graph))
 		}
 		for ps := range util.MergePackagesChans(cs...) {
 			imports.Merge(ps)
@@ -873,6 +1301,11 @@ func removeUnusedImports(imports util.Packages, targetDir string, updatePackages
 			pkg := path[len(targetDir+"/"):strings.LastIndex(path, "/")]
 			if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, ".go") && !imports[pkg] {
 				logrus.Debugf("Removing unused source file: '%s'", path)
+				if strings.HasSuffix(path, "_test.go") {
+					recordDeletion(path, "test file")
+				} else {
+					recordDeletion(path, "package not imported")
+				}
 				if err := os.Remove(path); err != nil {
 					if os.IsNotExist(err) {
 						return nil
@@ -886,6 +1319,7 @@ func removeUnusedImports(imports util.Packages, targetDir string, updatePackages
 		pkg := path[len(targetDir+"/"):]
 		if !imports[pkg] && !importsParents[pkg] {
 			logrus.Infof("Removing unused dir: '%s'", path)
+			recordDeletion(path, "not imported")
 			err := os.RemoveAll(path)
 			if err == nil {
 				return filepath.SkipDir
@@ -919,6 +1353,7 @@ func removeExcludes(excludes []string, targetDir string) error {
 		pkg := path[len(targetDir+"/"):]
 		if exclude[pkg] {
 			logrus.Infof("Removing excluded dir: '%s'", path)
+			recordDeletion(path, "excluded")
 			err := os.RemoveAll(path)
 			if err == nil {
 				return filepath.SkipDir
@@ -951,6 +1386,7 @@ func removeEmptyDirs(targetDir string) error {
 				err := os.Remove(path)
 				if err == nil {
 					logrus.Infof("Removed Empty dir: '%s'", path)
+					recordDeletion(path, "empty after cleanup")
 					count++
 					return filepath.SkipDir
 				}
@@ -983,7 +1419,16 @@ func guessRootPackage(dir string) string {
 	return dir[len(srcPath+"/"):]
 }
 
+// cleanup removes what the project doesn't need from the target dir and writes trash.lock
 func cleanup(update bool, dir, targetDir string, trashConf *conf.Conf) error {
+	if err := cleanVendor(update, dir, targetDir, trashConf); err != nil {
+		return err
+	}
+	return writeLock(dir, targetDir, trashConf)
+}
+
+// cleanVendor removes the excluded packages, the packages and test files the project doesn't need, and the dirs left empty
+func cleanVendor(update bool, dir, targetDir string, trashConf *conf.Conf) error {
 	rootPackage := trashConf.Package
 	if rootPackage == "" {
 		rootPackage = guessRootPackage(dir)
@@ -1000,6 +1445,9 @@ func cleanup(update bool, dir, targetDir string, trashConf *conf.Conf) error {
 		for _, i := range trashConf.Imports {
 			if i.Update {
 				updatePackages[i.Package] = true
+				for _, m := range i.PathMappings() {
+					updatePackages[m.Package] = true
+				}
 			}
 		}
 		logrus.Infof("Updated packages %v", updatePackages)
@@ -1017,14 +1465,27 @@ func cleanup(update bool, dir, targetDir string, trashConf *conf.Conf) error {
 	if err := removeEmptyDirs(targetDir); err != nil {
 		logrus.Errorf("Error removing empty dirs: %v", err)
 	}
+	return nil
+}
+
+// writeLock records the imports the target dir has in trash.lock
+func writeLock(dir, targetDir string, trashConf *conf.Conf) error {
 	writeConf := conf.Conf{
 		Package:  trashConf.Package,
 		Imports:  []conf.Import{},
 		Excludes: trashConf.Excludes,
+		Packages: trashConf.Packages,
+	}
+	for _, f := range trashConf.Flattened {
+		if _, err := os.Stat(path.Join(dir, targetDir, f.Package)); err == nil {
+			writeConf.Flattened = append(writeConf.Flattened, f)
+		}
 	}
 	for _, i := range trashConf.Imports {
 		pth := dir + "/" + targetDir + "/" + i.Package
-		if _, err := os.Stat(pth); err != nil {
+		if mappingVendored(path.Join(dir, targetDir), i) {
+			writeConf.Imports = append(writeConf.Imports, i)
+		} else if _, err := os.Stat(pth); err != nil {
 			if os.IsNotExist(err) {
 				logrus.Warnf("Package '%s' has been completely removed: it's probably useless (in %s)", i.Package, trashConf.ConfFile())
 			} else {
@@ -1040,5 +1501,18 @@ func cleanup(update bool, dir, targetDir string, trashConf *conf.Conf) error {
 		return err
 	}
 	os.RemoveAll(path.Join(dir, "trash.lock"))
-	return ioutil.WriteFile("trash.lock", data, 0755)
+	return ioutil.WriteFile(path.Join(dir, "trash.lock"), data, 0755)
+}
+
+// mappingVendored tells if a package the import maps is still in the vendor dir
+func mappingVendored(vendorDir string, i conf.Import) bool {
+	for _, m := range i.PathMappings() {
+		if i.Package == m.Package || strings.HasPrefix(i.Package, m.Package+"/") {
+			continue // the import's own dir tells
+		}
+		if _, err := os.Stat(path.Join(vendorDir, m.Package)); err == nil {
+			return true
+		}
+	}
+	return false
 }
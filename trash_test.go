@@ -4,10 +4,17 @@ import (
 	"fmt"
 	"go/parser"
 	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
 )
 
 func TestParentPackages(t *testing.T) {
@@ -48,8 +55,185 @@ func TestListPackages(t *testing.T) {
 	assert := require.New(t)
 	p := listPackages("github.com/rancher/trash", "vendor")
 	logrus.Debug(p)
-	assert.Equal(3, len(p))
+	assert.Equal(4, len(p))
 	assert.Contains(p, "github.com/rancher/trash")
 	assert.Contains(p, "github.com/rancher/trash/util")
 	assert.Contains(p, "github.com/rancher/trash/conf")
+	assert.Contains(p, "github.com/rancher/trash/semver")
+}
+
+func TestCopyMappings(t *testing.T) {
+	assert := require.New(t)
+
+	vendorDir, err := ioutil.TempDir("", "trash-mappings")
+	assert.Nil(err)
+	defer os.RemoveAll(vendorDir)
+	write := func(file, content string) {
+		assert.Nil(os.MkdirAll(filepath.Dir(filepath.Join(vendorDir, file)), 0755))
+		assert.Nil(ioutil.WriteFile(filepath.Join(vendorDir, file), []byte(content), 0644))
+	}
+	write("k8s.io/kubernetes/staging/src/k8s.io/api/api.go", "package api")
+	write("k8s.io/kubernetes/staging/src/k8s.io/apimachinery/a.go", "package apimachinery")
+	write("k8s.io/kubernetes/cmd/tool/main.go", "package main")
+	write("k8s.io/api/stale.go", "package api")
+	write("example.com/tool/old.go", "package tool")
+
+	i := conf.Import{Package: "k8s.io/kubernetes", Options: conf.Options{Staging: true, Mappings: []conf.Mapping{
+		{Package: "example.com/tool", Dir: "cmd/tool"},
+		{Package: "example.com/missing", Dir: "missing"},
+	}}}
+	trashConf := &conf.Conf{Imports: []conf.Import{i, {Package: "k8s.io/client-go"}}}
+	trashConf.Dedupe()
+	assert.Nil(copyMappings(vendorDir, trashConf, i))
+	for file, exists := range map[string]bool{
+		"k8s.io/api/api.go":          true,
+		"k8s.io/api/stale.go":        false,
+		"k8s.io/apimachinery/a.go":   true,
+		"k8s.io/kubernetes/cmd/tool": true,
+		"example.com/tool/main.go":   true,
+		"example.com/tool/old.go":    false,
+		"example.com/missing":        false,
+	} {
+		_, err := os.Stat(filepath.Join(vendorDir, file))
+		assert.Equal(exists, err == nil, file)
+	}
+
+	for pkg, covering := range map[string]string{
+		"k8s.io/kubernetes/pkg/api": "k8s.io/kubernetes",
+		"k8s.io/client-go/rest":     "k8s.io/client-go",
+		"k8s.io/api/core/v1":        "k8s.io/kubernetes",
+		"example.com/tool":          "k8s.io/kubernetes",
+		"example.com/toolbox":       "",
+	} {
+		c, _ := coveringImport(trashConf, pkg)
+		assert.Equal(covering, c.Package, pkg)
+	}
+	assert.True(mappingVendored(vendorDir, i))
+
+	for _, other := range []conf.Import{{Package: "example.com/tool"}, {Package: "k8s.io/api"}, {Package: "example.com"}} {
+		write(other.Package+"/own.go", "package own")
+		trashConf := &conf.Conf{Imports: []conf.Import{i, other}}
+		trashConf.Dedupe()
+		err := copyMappings(vendorDir, trashConf, i)
+		assert.NotNil(err, other.Package)
+		assert.Contains(err.Error(), "'"+other.Package+"' vendors too", other.Package)
+		_, err = os.Stat(filepath.Join(vendorDir, other.Package, "own.go"))
+		assert.Nil(err, "%s is kept", other.Package)
+	}
+}
+
+func TestLeaveOutTestImports(t *testing.T) {
+	assert := require.New(t)
+	defer func(n bool) { noTest = n }(noTest)
+
+	imports := []conf.Import{
+		{Package: "example.com/a", Version: "v1"},
+		{Package: "example.com/t", Version: "v1", Options: conf.Options{Test: true}},
+		{Package: "example.com/u", Version: "v1", Options: conf.Options{Test: true}, Update: true},
+	}
+	newConf := func() *conf.Conf {
+		trashConf := &conf.Conf{Imports: append([]conf.Import{}, imports...)}
+		trashConf.Dedupe()
+		return trashConf
+	}
+
+	noTest = false
+	trashConf := newConf()
+	leaveOutTestImports(false, trashConf)
+	assert.Len(trashConf.Imports, 3, "test-only imports are vendored without --no-test")
+
+	noTest = true
+	trashConf = newConf()
+	leaveOutTestImports(false, trashConf)
+	assert.Equal([]conf.Import{imports[0]}, trashConf.Imports)
+	_, ok := trashConf.Get("example.com/t")
+	assert.False(ok)
+
+	trashConf = newConf()
+	leaveOutTestImports(true, trashConf)
+	assert.Equal(imports[:2], trashConf.Imports, "update mode leaves the test-only imports it doesn't update alone")
+}
+
+func TestUpdateOnePackage(t *testing.T) {
+	assert := require.New(t)
+
+	wd, err := os.Getwd()
+	assert.Nil(err)
+	defer os.Chdir(wd)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	root, err := ioutil.TempDir("", "trash-update")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=trash", "-c", "user.email=trash@example.com"}, args...)...)
+		cmd.Dir = dir
+		bytes, err := cmd.CombinedOutput()
+		assert.Nil(err, "%s", bytes)
+		return strings.TrimSpace(string(bytes))
+	}
+	commit := func(dir, content string) {
+		assert.Nil(ioutil.WriteFile(filepath.Join(dir, "up.go"), []byte("package up // "+content+"\n"), 0644))
+		git(dir, "add", "-A")
+		git(dir, "commit", "-q", "-m", content)
+	}
+	trashDir := filepath.Join(root, "cache")
+	dir := filepath.Join(root, "proj")
+	pkgs := []string{"example.com/a", "example.com/b"}
+	for _, pkg := range pkgs {
+		up := filepath.Join(root, "up", pkg)
+		assert.Nil(os.MkdirAll(up, 0755))
+		git(up, "init", "-q")
+		git(up, "checkout", "-q", "-b", "master")
+		commit(up, "one")
+	}
+	assert.Nil(os.MkdirAll(dir, 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "vendor.conf"), []byte("example.com/proj\nexample.com/a master\nexample.com/b master\n"), 0644))
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport (\n\t_ \"example.com/a\"\n\t_ \"example.com/b\"\n)\n"), 0644))
+
+	run := func(args ...string) error {
+		// update mode moves the cached repos to the vendor dir: clone them again rather than have trash go get them
+		for _, pkg := range pkgs {
+			if _, err := os.Stat(filepath.Join(trashDir, "src", pkg)); os.IsNotExist(err) {
+				git(root, "clone", "-q", filepath.Join(root, "up", pkg), filepath.Join(trashDir, "src", pkg))
+			}
+		}
+		return newApp().Run(append([]string{"trash", "--cache", trashDir, "-C", dir}, args...))
+	}
+	vendored := func(pkg string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "vendor", pkg, "up.go"))
+		assert.Nil(err)
+		return string(data)
+	}
+	lock := func() *conf.Conf {
+		lock, err := conf.Parse(filepath.Join(dir, "trash.lock"))
+		assert.Nil(err)
+		return lock
+	}
+
+	assert.Nil(run())
+	before := lock()
+	// a full run would clean this up: update mode must not touch the packages it doesn't update
+	extra := filepath.Join(dir, "vendor", "example.com", "b", "extra.txt")
+	assert.Nil(ioutil.WriteFile(extra, []byte("extra\n"), 0644))
+	for _, pkg := range pkgs {
+		commit(filepath.Join(root, "up", pkg), "two")
+	}
+
+	assert.Nil(run("-u", "example.com/a"))
+	assert.Equal("package up // two\n", vendored("example.com/a"))
+	assert.Equal("package up // one\n", vendored("example.com/b"))
+	_, err = os.Stat(extra)
+	assert.Nil(err, "example.com/b's vendor tree is left alone")
+
+	after := lock()
+	assert.Len(after.Imports, 2, "trash.lock still has the imports that weren't updated")
+	a, _ := before.Get("example.com/a")
+	updated, ok := after.Get("example.com/a")
+	assert.True(ok)
+	assert.NotEqual(a.Commit, updated.Commit)
+	b, _ := before.Get("example.com/b")
+	kept, ok := after.Get("example.com/b")
+	assert.True(ok)
+	assert.Equal(b, kept)
 }
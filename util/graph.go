@@ -0,0 +1,119 @@
+package util
+
+import (
+	"sort"
+	"sync"
+)
+
+type EdgeKind int
+
+const (
+	Import EdgeKind = iota
+	CgoInclude
+)
+
+func (k EdgeKind) String() string {
+	if k == CgoInclude {
+		return "cgo include"
+	}
+	return "import"
+}
+
+// Graph records which package imports which. It's safe for concurrent use, and a nil *Graph records nothing.
+type Graph struct {
+	mu    sync.Mutex
+	edges map[string]map[string]EdgeKind
+}
+
+func NewGraph() *Graph {
+	return &Graph{edges: map[string]map[string]EdgeKind{}}
+}
+
+func (g *Graph) Add(from, to string, kind EdgeKind) {
+	if g == nil || from == to {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.edges[from] == nil {
+		g.edges[from] = map[string]EdgeKind{}
+	}
+	if _, ok := g.edges[from][to]; !ok || kind == Import {
+		g.edges[from][to] = kind
+	}
+}
+
+// Edges returns the packages imported by from, sorted
+func (g *Graph) Edges(from string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := make([]string, 0, len(g.edges[from]))
+	for to := range g.edges[from] {
+		r = append(r, to)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (g *Graph) Kind(from, to string) EdgeKind {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.edges[from][to]
+}
+
+// Nodes returns all the packages in the graph, sorted
+func (g *Graph) Nodes() []string {
+	g.mu.Lock()
+	nodes := Packages{}
+	for from, tos := range g.edges {
+		nodes[from] = true
+		for to := range tos {
+			nodes[to] = true
+		}
+	}
+	g.mu.Unlock()
+	r := make([]string, 0, len(nodes))
+	for n := range nodes {
+		r = append(r, n)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// ShortestPath finds the shortest import chain from any of the roots to the package.
+// It returns nil if there's none.
+func (g *Graph) ShortestPath(roots Packages, to string) []string {
+	prev := map[string]string{}
+	queue := []string{}
+	for _, r := range sortedKeys(roots) {
+		prev[r] = ""
+		queue = append(queue, r)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == to {
+			path := []string{}
+			for ; p != ""; p = prev[p] {
+				path = append([]string{p}, path...)
+			}
+			return path
+		}
+		for _, next := range g.Edges(p) {
+			if _, seen := prev[next]; !seen {
+				prev[next] = p
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(p Packages) []string {
+	r := make([]string, 0, len(p))
+	for k := range p {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
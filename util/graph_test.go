@@ -0,0 +1,27 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortestPath(t *testing.T) {
+	assert := require.New(t)
+
+	g := NewGraph()
+	g.Add("root/a", "x/one", Import)
+	g.Add("root/b", "x/two", Import)
+	g.Add("x/one", "x/two", Import)
+	g.Add("x/two", "x/three", Import)
+	g.Add("x/three", "x/three/include", CgoInclude)
+
+	roots := Packages{"root/a": true, "root/b": true}
+	assert.Equal([]string{"root/b", "x/two", "x/three", "x/three/include"}, g.ShortestPath(roots, "x/three/include"))
+	assert.Equal(CgoInclude, g.Kind("x/three", "x/three/include"))
+	assert.Nil(g.ShortestPath(roots, "x/four"))
+	assert.Equal([]string{"root/a", "root/b", "x/one", "x/three", "x/three/include", "x/two"}, g.Nodes())
+
+	var none *Graph
+	none.Add("root/a", "x/one", Import)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/util"
+)
+
+var whyCommand = cli.Command{
+	Name:      "why",
+	Usage:     "Explain why a package is vendored, or why it is not",
+	ArgsUsage: "<package>",
+	Action:    why,
+}
+
+func why(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelp(c, "why")
+		return fmt.Errorf("expected 1 argument, got %d", c.NArg())
+	}
+	dir, _, trashConf, err := prepare(c)
+	if err != nil {
+		logrus.Error(err)
+		return err
+	}
+	targetDir := c.GlobalString("target")
+	rootPackage := trashConf.Package
+	if rootPackage == "" {
+		rootPackage = guessRootPackage(dir)
+	}
+	os.Chdir(dir)
+
+	lock, err := conf.Parse("trash.lock")
+	if err != nil {
+		logrus.Debugf("Could not read trash.lock: %s", err)
+		lock = &conf.Conf{}
+	}
+	graph := util.NewGraph()
+	imports := collectImportGraph(rootPackage, targetDir, targetDir, graph)
+	roots := listPackages(rootPackage, targetDir)
+
+	for _, line := range explain(c.Args().First(), trashConf, lock, roots, imports, graph) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// explain tells why pkg is kept in the vendor dir, or why it's not there
+func explain(pkg string, trashConf, lock *conf.Conf, roots, imports util.Packages, graph *util.Graph) []string {
+	r := []string{}
+	if roots[pkg] {
+		return append(r, fmt.Sprintf("%s is a package of the project", pkg))
+	}
+
+	origin := ""
+	if i, ok := coveringImport(trashConf, pkg); ok {
+		origin = fmt.Sprintf("it comes from '%s' %s in %s", i.Package, i.Version, trashConf.ConfFile())
+	} else if i, ok := coveringImport(lock, pkg); ok && i.From != "" {
+		origin = fmt.Sprintf("it comes from '%s' %s, which is not in %s: it's pulled in by %s", i.Package, i.Version, trashConf.ConfFile(), i.From)
+	} else if ok {
+		origin = fmt.Sprintf("it comes from '%s' %s, which is not in %s: it's pulled in by a transitive dependency's config", i.Package, i.Version, trashConf.ConfFile())
+	}
+
+	kept := false
+	for _, p := range trashConf.Packages {
+		if p == pkg {
+			r = append(r, fmt.Sprintf("%s is kept because of the package=%s entry in %s", pkg, p, trashConf.ConfFile()))
+			kept = true
+		}
+	}
+	for _, p := range lock.Packages {
+		if p == pkg && !kept {
+			// trash.lock has the package= entries of the transitive imports' conf files too
+			r = append(r, fmt.Sprintf("%s is kept because of a package=%s entry in a transitive dependency's config (it's in trash.lock)", pkg, p))
+			kept = true
+		}
+	}
+	if path := graph.ShortestPath(roots, pkg); imports[pkg] && path != nil {
+		r = append(r, fmt.Sprintf("%s is kept because it's needed by:", pkg))
+		r = append(r, "  "+path[0])
+		for k := 1; k < len(path); k++ {
+			r = append(r, fmt.Sprintf("  -> %s (%s)", path[k], graph.Kind(path[k-1], path[k])))
+		}
+		kept = true
+	}
+	if kept {
+		if origin != "" {
+			r = append(r, origin)
+		}
+		return r
+	}
+
+	for _, e := range trashConf.Excludes {
+		if pkg == e || strings.HasPrefix(pkg, e+"/") {
+			return append(r, fmt.Sprintf("%s is not vendored: it's excluded by '-%s' in %s", pkg, e, trashConf.ConfFile()))
+		}
+	}
+	if origin == "" {
+		return append(r, fmt.Sprintf("%s is not vendored: no package of the project needs it, and it's not in %s or trash.lock", pkg, trashConf.ConfFile()))
+	}
+	return append(r, fmt.Sprintf("%s is not vendored: no package of the project needs it, so the cleanup removes it", pkg), origin)
+}
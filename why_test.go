@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rancher/trash/conf"
+	"github.com/rancher/trash/util"
+)
+
+func TestExplain(t *testing.T) {
+	assert := require.New(t)
+
+	trashConf := &conf.Conf{
+		Imports:  []conf.Import{{Package: "example.com/a", Version: "v1"}},
+		Excludes: []string{"example.com/a/docs"},
+		Packages: []string{"example.com/a/plugin"},
+	}
+	trashConf.Dedupe()
+	lock := &conf.Conf{
+		Imports:  []conf.Import{{Package: "example.com/a", Version: "v1"}, {Package: "example.com/t", Version: "v2"}},
+		Packages: []string{"example.com/a/plugin", "example.com/t/driver"},
+	}
+	lock.Dedupe()
+
+	g := util.NewGraph()
+	g.Add("example.com/proj", "example.com/a/lib", util.Import)
+	g.Add("example.com/a/lib", "example.com/t", util.Import)
+	roots := util.Packages{"example.com/proj": true}
+	imports := util.Packages{"example.com/proj": true, "example.com/a/lib": true, "example.com/t": true}
+
+	r := explain("example.com/t", trashConf, lock, roots, imports, g)
+	assert.Equal([]string{
+		"example.com/t is kept because it's needed by:",
+		"  example.com/proj",
+		"  -> example.com/a/lib (import)",
+		"  -> example.com/t (import)",
+	}, r[:4])
+	assert.Contains(r[4], "'example.com/t' v2")
+	assert.Contains(r[4], "transitive dependency")
+
+	r = explain("example.com/a/plugin", trashConf, lock, roots, imports, g)
+	assert.Len(r, 2)
+	assert.Contains(r[0], "package=example.com/a/plugin entry in ")
+	r = explain("example.com/t/driver", trashConf, lock, roots, imports, g)
+	assert.Equal("example.com/t/driver is kept because of a package=example.com/t/driver entry in a transitive dependency's config (it's in trash.lock)", r[0])
+	assert.Contains(r[1], "'example.com/t' v2")
+	r = explain("example.com/a/docs/x", trashConf, lock, roots, imports, g)
+	assert.Contains(r[0], "excluded by '-example.com/a/docs'")
+	r = explain("example.com/a/unused", trashConf, lock, roots, imports, g)
+	assert.Contains(r[0], "the cleanup removes it")
+}